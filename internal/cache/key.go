@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// keyData is everything that makes two requests give the same answer
+type keyData struct {
+	Provider string                   `json:"provider"`
+	Model    string                   `json:"model"`
+	Prompt   string                   `json:"prompt"`
+	Images   []providers.Image        `json:"images,omitempty"`
+	History  []providers.Message      `json:"history,omitempty"`
+	Options  providers.RequestOptions `json:"options"`
+	Tools    []string                 `json:"tools,omitempty"`
+	Context  string                   `json:"context"`
+}
+
+// Key returns the cache key of a request to the named provider and model.
+// The prompt is normalized, so that prompts differing only in case,
+// whitespace or trailing punctuation share a key. The recent commands are
+// only part of the key when recentCommands is set.
+func Key(provider, model string, req *providers.Request, recentCommands bool) string {
+	data := keyData{
+		Provider: provider,
+		Model:    model,
+		Prompt:   NormalizePrompt(req.Prompt()),
+		Options:  req.Options,
+		Context:  contextHash(req.Context, recentCommands),
+	}
+	if n := len(req.Messages); n > 0 {
+		data.Images = req.Messages[n-1].Images
+		data.History = req.Messages[:n-1]
+	}
+	for _, tool := range req.Tools {
+		data.Tools = append(data.Tools, tool.Definition().Name)
+	}
+	sort.Strings(data.Tools)
+
+	encoded, _ := json.Marshal(data)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizePrompt lowercases a prompt, collapses its whitespace and drops
+// trailing punctuation
+func NormalizePrompt(prompt string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+	return strings.TrimRight(normalized, " ?!.")
+}
+
+// contextHash hashes the context as it is sent to the model, including the
+// git status, so that an answer is only reused for the same situation. The
+// recent commands change with every command run, and are left out unless
+// recentCommands is set.
+func contextHash(ctx *providers.Context, recentCommands bool) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if !recentCommands && len(ctx.RecentCommands) > 0 {
+		withoutCommands := *ctx
+		withoutCommands.RecentCommands = nil
+		ctx = &withoutCommands
+	}
+
+	sum := sha256.Sum256([]byte(providers.BuildSystemContext(ctx)))
+	return hex.EncodeToString(sum[:])
+}
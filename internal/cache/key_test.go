@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+func TestNormalizePrompt(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"How do I untar a file?", "how do i untar a file"},
+		{"  how   do I\tuntar a file ?! ", "how do i untar a file"},
+		{"list files...", "list files"},
+		{"what is 3.5", "what is 3.5"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizePrompt(tt.prompt); got != tt.want {
+			t.Errorf("NormalizePrompt(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+func testContext() *providers.Context {
+	return &providers.Context{
+		WorkingDirectory: "/home/me/project",
+		Shell:            "bash",
+		Git:              &providers.GitContext{Repository: "project", Branch: "main", Status: "M main.go"},
+		RecentCommands:   []providers.CommandHistory{{Command: "make test", ExitCode: 2}},
+	}
+}
+
+func TestKeySharedByEquivalentPrompts(t *testing.T) {
+	a := Key("claude", "model", providers.NewRequest("How do I untar a file?", testContext()), false)
+	b := Key("claude", "model", providers.NewRequest("how do i  untar a file", testContext()), false)
+	if a != b {
+		t.Error("prompts differing in case, whitespace and punctuation have different keys")
+	}
+}
+
+func TestKeyChangesWithWhatIsSent(t *testing.T) {
+	base := Key("claude", "model", providers.NewRequest("why did that fail", testContext()), false)
+
+	changes := map[string]func(req *providers.Request){
+		"git status": func(req *providers.Request) { req.Context.Git.Status = "M other.go" },
+		"directory":  func(req *providers.Request) { req.Context.WorkingDirectory = "/tmp" },
+		"prompt":     func(req *providers.Request) { req.Messages[0].Content = "why did that pass" },
+		"max tokens": func(req *providers.Request) { req.Options.MaxTokens = 100 },
+		"tools":      func(req *providers.Request) { req.Tools = []providers.Tool{fakeTool{}} },
+		"no context": func(req *providers.Request) { req.Context = nil },
+		"new history": func(req *providers.Request) {
+			req.Messages = append([]providers.Message{{Role: providers.RoleUser, Content: "hi"}}, req.Messages...)
+		},
+	}
+
+	for name, change := range changes {
+		req := providers.NewRequest("why did that fail", testContext())
+		change(req)
+		if Key("claude", "model", req, false) == base {
+			t.Errorf("changing the %s keeps the key", name)
+		}
+	}
+
+	if Key("gpt", "model", providers.NewRequest("why did that fail", testContext()), false) == base {
+		t.Error("different providers share a key")
+	}
+	if Key("claude", "other", providers.NewRequest("why did that fail", testContext()), false) == base {
+		t.Error("different models share a key")
+	}
+}
+
+func TestKeyRecentCommands(t *testing.T) {
+	changes := map[string]func(req *providers.Request){
+		"exit code":   func(req *providers.Request) { req.Context.RecentCommands[0].ExitCode = 1 },
+		"command":     func(req *providers.Request) { req.Context.RecentCommands[0].Command = "make lint" },
+		"no commands": func(req *providers.Request) { req.Context.RecentCommands = nil },
+	}
+
+	for _, recentCommands := range []bool{false, true} {
+		base := Key("claude", "model", providers.NewRequest("why did that fail", testContext()), recentCommands)
+
+		for name, change := range changes {
+			req := providers.NewRequest("why did that fail", testContext())
+			change(req)
+			if changed := Key("claude", "model", req, recentCommands) != base; changed != recentCommands {
+				t.Errorf("with recent commands keyed %v, changing the %s changes the key: %v", recentCommands, name, changed)
+			}
+		}
+	}
+
+	// Leaving the commands out of the key does not change the request
+	req := providers.NewRequest("why did that fail", testContext())
+	Key("claude", "model", req, false)
+	if len(req.Context.RecentCommands) != 1 {
+		t.Error("the request's recent commands were dropped")
+	}
+}
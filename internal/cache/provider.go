@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider serves repeated requests from a Store and stores the responses
+// of the provider it wraps
+type Provider struct {
+	providers.ProviderV2
+
+	name           string
+	store          *Store
+	recentCommands bool
+}
+
+// Wrap returns a provider that caches the responses of next, which is
+// configured under name. With recentCommands set, answers are only reused
+// for the same recent commands.
+func Wrap(name string, next providers.ProviderV2, store *Store, recentCommands bool) *Provider {
+	return &Provider{
+		ProviderV2:     next,
+		name:           name,
+		store:          store,
+		recentCommands: recentCommands,
+	}
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	key := p.key(req)
+
+	if cached, ok := p.store.Get(key); ok {
+		cached.Cached = true
+		cached.ResponseTime = 0
+		return cached, nil
+	}
+
+	req, usedTools := watchToolCalls(req)
+	resp, err := p.ProviderV2.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failure to cache must not fail the request
+	if !usedTools() {
+		p.store.Put(key, p.name, req.Prompt(), resp)
+	}
+
+	return resp, nil
+}
+
+// Stream implements the providers.ProviderV2 interface. A cached response is
+// replayed as a single chunk; otherwise the streamed text is collected and
+// stored once the stream completes.
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	key := p.key(req)
+
+	if cached, ok := p.store.Get(key); ok {
+		out := make(chan providers.StreamResponse, 3)
+		if cached.Thinking != "" {
+			out <- providers.StreamResponse{Thinking: cached.Thinking}
+		}
+		out <- providers.StreamResponse{Text: cached.Text}
+		out <- providers.StreamResponse{
+			Done: true,
+			Metadata: map[string]interface{}{
+				providers.MetadataModel:  cached.Model,
+				providers.MetadataCached: true,
+			},
+		}
+		close(out)
+		return out, nil
+	}
+
+	req, usedTools := watchToolCalls(req)
+	in, err := p.ProviderV2.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan providers.StreamResponse)
+	go func() {
+		defer close(out)
+
+		startTime := time.Now()
+		var text, thinking strings.Builder
+
+		for chunk := range in {
+			if chunk.Done && chunk.Error == nil && !usedTools() {
+				resp := streamedResponse(p.name, text.String(), chunk.Metadata, time.Since(startTime))
+				resp.Thinking = thinking.String()
+				p.store.Put(key, p.name, req.Prompt(), resp)
+			}
+			text.WriteString(chunk.Text)
+			thinking.WriteString(chunk.Thinking)
+
+			if !providers.SendChunk(ctx, out, chunk) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// watchToolCalls returns a copy of req that records whether the model
+// called a tool. Answers built from tool output are not stored, since the
+// files and commands they read may have changed by the next request.
+func watchToolCalls(req *providers.Request) (*providers.Request, func() bool) {
+	if len(req.Tools) == 0 {
+		return req, func() bool { return false }
+	}
+
+	var called int32
+	watched := *req
+	watched.OnToolCall = func(call providers.ToolCall) {
+		atomic.StoreInt32(&called, 1)
+		if req.OnToolCall != nil {
+			req.OnToolCall(call)
+		}
+	}
+
+	return &watched, func() bool { return atomic.LoadInt32(&called) == 1 }
+}
+
+// key returns the cache key of a request to the wrapped provider
+func (p *Provider) key(req *providers.Request) string {
+	return Key(p.name, p.GetInfo().Model, req, p.recentCommands)
+}
+
+// streamedResponse builds the response to cache from a completed stream
+func streamedResponse(name, text string, metadata map[string]interface{}, elapsed time.Duration) *providers.Response {
+	model, _ := metadata[providers.MetadataModel].(string)
+	inputTokens, _ := metadata[providers.MetadataInputTokens].(int)
+	outputTokens, _ := metadata[providers.MetadataOutputTokens].(int)
+
+	return &providers.Response{
+		Text:         text,
+		Model:        model,
+		Provider:     name,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		ResponseTime: elapsed,
+	}
+}
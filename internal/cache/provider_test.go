@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+type fakeTool struct{}
+
+func (fakeTool) Definition() providers.ToolDefinition {
+	return providers.ToolDefinition{Name: "read_file"}
+}
+
+func (fakeTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	return "contents", nil
+}
+
+// fakeProvider answers every request, calling its tool first when callTool
+// is set, and counts the requests that reached it
+type fakeProvider struct {
+	providers.ProviderV2
+	callTool bool
+	calls    int
+}
+
+func (f *fakeProvider) GetInfo() providers.ProviderInfo {
+	return providers.ProviderInfo{Model: "fake-model"}
+}
+
+func (f *fakeProvider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	f.calls++
+	if f.callTool {
+		req.CallTool(ctx, "1", "read_file", nil)
+	}
+	return &providers.Response{Text: "answer", Thinking: "reasoning", Model: "fake-model"}, nil
+}
+
+func (f *fakeProvider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	f.calls++
+	out := make(chan providers.StreamResponse, 3)
+	out <- providers.StreamResponse{Thinking: "reasoning"}
+	out <- providers.StreamResponse{Text: "answer"}
+	out <- providers.StreamResponse{Done: true, Metadata: map[string]interface{}{providers.MetadataModel: "fake-model"}}
+	close(out)
+	return out, nil
+}
+
+func newTestProvider(t *testing.T, next *fakeProvider) *Provider {
+	return Wrap("fake", next, NewStore(t.TempDir(), time.Hour, 0), false)
+}
+
+func TestSendServesRepeatedRequests(t *testing.T) {
+	next := &fakeProvider{}
+	p := newTestProvider(t, next)
+
+	for i := 0; i < 2; i++ {
+		resp, err := p.Send(context.Background(), providers.NewRequest("untar a file", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text != "answer" || resp.Thinking != "reasoning" {
+			t.Errorf("response %d = %q / %q", i, resp.Text, resp.Thinking)
+		}
+		if cached := i > 0; resp.Cached != cached {
+			t.Errorf("response %d cached = %v", i, resp.Cached)
+		}
+	}
+
+	if next.calls != 1 {
+		t.Errorf("provider called %d times, want 1", next.calls)
+	}
+}
+
+func TestSendSkipsAnswersFromTools(t *testing.T) {
+	next := &fakeProvider{callTool: true}
+	p := newTestProvider(t, next)
+
+	called := 0
+	for i := 0; i < 2; i++ {
+		req := providers.NewRequest("what does main.go do", nil)
+		req.Tools = []providers.Tool{fakeTool{}}
+		req.OnToolCall = func(providers.ToolCall) { called++ }
+
+		if _, err := p.Send(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if next.calls != 2 {
+		t.Errorf("provider called %d times, want 2", next.calls)
+	}
+	if called != 2 {
+		t.Errorf("OnToolCall called %d times, want 2", called)
+	}
+}
+
+// setupContext is the context gathered with the configuration written by
+// how setup, which includes the last five commands
+func setupContext(commands ...string) *providers.Context {
+	ctx := &providers.Context{WorkingDirectory: "/home/me/downloads", Shell: "bash"}
+	for _, command := range commands {
+		ctx.RecentCommands = append(ctx.RecentCommands, providers.CommandHistory{Command: command})
+	}
+	return ctx
+}
+
+func TestSendServesRepeatedQuestionsWithRecentCommands(t *testing.T) {
+	next := &fakeProvider{}
+	p := newTestProvider(t, next)
+
+	// Commands run between the two questions do not matter
+	contexts := []*providers.Context{
+		setupContext("ls", "cd downloads", "ls -la", "file archive.tar.zst", "clear"),
+		setupContext("cd downloads", "ls -la", "file archive.tar.zst", "clear", "ls"),
+	}
+	for i, ctx := range contexts {
+		resp, err := p.Send(context.Background(), providers.NewRequest("how do I extract a .tar.zst file?", ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached := i > 0; resp.Cached != cached {
+			t.Errorf("response %d cached = %v", i, resp.Cached)
+		}
+	}
+
+	if next.calls != 1 {
+		t.Errorf("provider called %d times, want 1", next.calls)
+	}
+}
+
+func TestSendKeysOnRecentCommandsWhenConfigured(t *testing.T) {
+	next := &fakeProvider{}
+	p := Wrap("fake", next, NewStore(t.TempDir(), time.Hour, 0), true)
+
+	for _, command := range []string{"make test", "make test", "make lint"} {
+		if _, err := p.Send(context.Background(), providers.NewRequest("why did that fail", setupContext(command))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if next.calls != 2 {
+		t.Errorf("provider called %d times, want 2", next.calls)
+	}
+}
+
+func TestStreamReplaysThinking(t *testing.T) {
+	next := &fakeProvider{}
+	p := newTestProvider(t, next)
+
+	for i := 0; i < 2; i++ {
+		chunks, err := p.Stream(context.Background(), providers.NewRequest("untar a file", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var text, thinking string
+		for chunk := range chunks {
+			text += chunk.Text
+			thinking += chunk.Thinking
+		}
+		if text != "answer" || thinking != "reasoning" {
+			t.Errorf("stream %d = %q / %q", i, text, thinking)
+		}
+	}
+
+	if next.calls != 1 {
+		t.Errorf("provider called %d times, want 1", next.calls)
+	}
+}
@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Store defaults
+const (
+	DefaultTTL     = 24 * time.Hour
+	DefaultMaxSize = 50 * 1024 * 1024
+
+	entrySuffix = ".json"
+	statsFile   = "stats"
+)
+
+// Store keeps responses on disk, one file per entry. Entries expire after
+// the TTL, and the least recently used entries are evicted when the store
+// grows beyond its maximum size.
+type Store struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+
+	mu sync.Mutex
+}
+
+// entry is the on-disk form of a cached response
+type entry struct {
+	Provider  string              `json:"provider"`
+	Model     string              `json:"model"`
+	Prompt    string              `json:"prompt"`
+	CreatedAt time.Time           `json:"created_at"`
+	Response  *providers.Response `json:"response"`
+}
+
+// counters are the hit and miss counts kept for Stats
+type counters struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// Stats describes the contents and effectiveness of the store
+type Stats struct {
+	Entries int
+	Expired int
+	Size    int64
+	Oldest  time.Time
+	Newest  time.Time
+	Hits    int
+	Misses  int
+}
+
+// NewStore creates a store in dir. Zero settings use the defaults.
+func NewStore(dir string, ttl time.Duration, maxSize int64) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	return &Store{
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+	}
+}
+
+// DefaultDir returns the directory used for the cache by default
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "how", "responses"), nil
+}
+
+// Get returns the cached response for key, if there is an unexpired one
+func (s *Store) Get(key string) (*providers.Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.read(key)
+	if err != nil || time.Since(e.CreatedAt) > s.ttl || e.Response == nil {
+		s.count(false)
+		return nil, false
+	}
+
+	// Mark the entry as recently used for eviction
+	now := time.Now()
+	os.Chtimes(s.path(key), now, now)
+
+	s.count(true)
+	return e.Response, true
+}
+
+// Put stores a response under key and evicts entries beyond the size limit
+func (s *Store) Put(key, provider, prompt string, resp *providers.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(entry{
+		Provider:  provider,
+		Model:     resp.Model,
+		Prompt:    prompt,
+		CreatedAt: time.Now(),
+		Response:  resp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first, so readers never see a partial entry
+	tmp, err := os.CreateTemp(s.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	return s.evict()
+}
+
+// Clear removes all entries and resets the statistics, returning the number
+// of entries removed
+func (s *Store) Clear() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(s.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		removed++
+	}
+
+	if err := os.Remove(filepath.Join(s.dir, statsFile)); err != nil && !os.IsNotExist(err) {
+		return removed, fmt.Errorf("failed to reset cache statistics: %w", err)
+	}
+
+	return removed, nil
+}
+
+// Stats returns the number and size of entries and the hit rate
+func (s *Store) Stats() (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats Stats
+
+	files, err := s.entries()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, file := range files {
+		stats.Entries++
+		stats.Size += file.Size()
+
+		e, err := s.read(strings.TrimSuffix(file.Name(), entrySuffix))
+		if err != nil {
+			continue
+		}
+		if time.Since(e.CreatedAt) > s.ttl {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || e.CreatedAt.Before(stats.Oldest) {
+			stats.Oldest = e.CreatedAt
+		}
+		if e.CreatedAt.After(stats.Newest) {
+			stats.Newest = e.CreatedAt
+		}
+	}
+
+	c := s.counters()
+	stats.Hits, stats.Misses = c.Hits, c.Misses
+
+	return stats, nil
+}
+
+// Dir returns the directory holding the entries
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// evict removes entries unused for longer than the TTL, then the least
+// recently used entries until the store fits its maximum size
+func (s *Store) evict() error {
+	files, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	// Most recently used first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	var size int64
+	for _, file := range files {
+		size += file.Size()
+		expired := time.Since(file.ModTime()) > s.ttl
+		if size > s.maxSize || expired {
+			os.Remove(filepath.Join(s.dir, file.Name()))
+		}
+	}
+
+	return nil
+}
+
+// entries lists the entry files in the store
+func (s *Store) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var files []os.FileInfo
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), entrySuffix) || strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		if info, err := dirEntry.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+
+	return files, nil
+}
+
+// read loads the entry stored under key
+func (s *Store) read(key string) (*entry, error) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, err
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// path returns the file holding the entry for key
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, key+entrySuffix)
+}
+
+// count records a hit or a miss. Statistics are best effort, so failures
+// to update them are ignored.
+func (s *Store) count(hit bool) {
+	c := s.counters()
+	if hit {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+
+	if data, err := json.Marshal(c); err == nil && os.MkdirAll(s.dir, 0700) == nil {
+		os.WriteFile(filepath.Join(s.dir, statsFile), data, 0600)
+	}
+}
+
+// counters reads the hit and miss counts
+func (s *Store) counters() counters {
+	var c counters
+	if data, err := os.ReadFile(filepath.Join(s.dir, statsFile)); err == nil {
+		json.Unmarshal(data, &c)
+	}
+	return c
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Codilas/how/internal/cache"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the response cache",
+	Long:  `Inspect and clear the cache of previous answers.`,
+}
+
+var clearCacheCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear the response cache",
+	Run:   runClearCache,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show response cache statistics",
+	Run:   runCacheStats,
+}
+
+func init() {
+	cacheCmd.AddCommand(clearCacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
+
+func runClearCache(cmd *cobra.Command, args []string) {
+	store, err := openCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	removed, err := store.Clear()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error clearing cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🗑️ Removed %d cached responses.\n", removed)
+}
+
+func runCacheStats(cmd *cobra.Command, args []string) {
+	store, err := openCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Location: %s\n", store.Dir())
+	if cfg.Cache.Disabled {
+		fmt.Printf("Status:   %s\n", color.YellowString("disabled"))
+	}
+	fmt.Printf("Entries:  %d (%d expired)\n", stats.Entries, stats.Expired)
+	fmt.Printf("Size:     %s\n", formatBytes(stats.Size))
+
+	if stats.Entries > 0 {
+		fmt.Printf("Oldest:   %s\n", stats.Oldest.Format(time.DateTime))
+		fmt.Printf("Newest:   %s\n", stats.Newest.Format(time.DateTime))
+	}
+
+	lookups := stats.Hits + stats.Misses
+	if lookups > 0 {
+		fmt.Printf("Hits:     %d of %d lookups (%.0f%%)\n", stats.Hits, lookups, 100*float64(stats.Hits)/float64(lookups))
+	}
+}
+
+// openCache opens the response cache configured in cfg
+func openCache() (*cache.Store, error) {
+	dir := cfg.Cache.Dir
+	if dir == "" {
+		defaultDir, err := cache.DefaultDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate cache directory: %w", err)
+		}
+		dir = defaultDir
+	}
+
+	return cache.NewStore(dir, cfg.Cache.TTL, int64(cfg.Cache.MaxSizeMB)*1024*1024), nil
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
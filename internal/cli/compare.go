@@ -0,0 +1,350 @@
+package cli
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Codilas/how/pkg/extractor"
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/text"
+	"github.com/briandowns/spinner"
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+const (
+	// minColumnWidth is the narrowest column answers are shown side by
+	// side in; narrower terminals show them one after another
+	minColumnWidth = 40
+
+	// columnSeparator separates the columns of side-by-side answers
+	columnSeparator = " │ "
+
+	// maxCommandWidth truncates suggested commands in the comparison table
+	maxCommandWidth = 60
+)
+
+// compareResult is the answer of one provider in compare mode
+type compareResult struct {
+	name     string
+	resp     *providers.Response
+	err      error
+	latency  time.Duration
+	commands []string
+}
+
+// handleComparePrompt sends the prompt to several providers at once and
+// shows their answers next to each other, followed by the commands each
+// one suggested
+func handleComparePrompt(prompt string, names []string) {
+	names = compareProviders(names)
+
+	// Each provider must answer for itself
+	mng.SetFallback(nil, nil)
+
+	req := newRequest(prompt)
+
+	// Cancel the requests on Ctrl-C
+	reqCtx, stop := signal.NotifyContext(gocontext.Background(), os.Interrupt)
+	defer stop()
+
+	s := spinner.New(spinner.CharSets[14], 100)
+	s.Suffix = fmt.Sprintf(" Asking %s...", strings.Join(names, ", "))
+	if cfg.Display.Emoji {
+		s.Suffix = " 🤔" + s.Suffix
+	}
+	s.Start()
+
+	results := compare(reqCtx, names, req, s)
+	s.Stop()
+
+	if reqCtx.Err() != nil {
+		exitWithError(reqCtx.Err())
+	}
+
+	if width := terminalWidth(); columnWidth(width, len(results)) >= minColumnWidth {
+		displaySideBySide(results, width)
+	} else {
+		displaySequential(results)
+	}
+	displayCommandComparison(results)
+
+	for _, result := range results {
+		if result.err == nil {
+			return
+		}
+	}
+	os.Exit(1)
+}
+
+// compareProviders checks the providers to compare, applying the spending
+// caps to each and dropping duplicates
+func compareProviders(names []string) []string {
+	var compared []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = applyBudget(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+
+		if _, err := mng.GetProvider(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Available providers: %v\n", mng.Names())
+			os.Exit(1)
+		}
+
+		seen[name] = true
+		compared = append(compared, name)
+	}
+
+	return compared
+}
+
+// compare sends req to every provider concurrently and waits for all of
+// them to answer or fail
+func compare(ctx gocontext.Context, names []string, req *providers.Request, s *spinner.Spinner) []compareResult {
+	results := make([]compareResult, len(names))
+	commandExtractor := extractor.NewCommandExtractor()
+
+	// Keep tool calls from mixing with the spinner and each other
+	var outputMu sync.Mutex
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			providerReq := *req
+			if req.OnToolCall != nil {
+				providerReq.OnToolCall = func(call providers.ToolCall) {
+					outputMu.Lock()
+					defer outputMu.Unlock()
+
+					s.Stop()
+					fmt.Fprint(os.Stderr, dim(name+": "))
+					showToolCall(call)
+					s.Start()
+				}
+			}
+
+			startTime := time.Now()
+			resp, err := mng.Send(ctx, name, &providerReq)
+			result := compareResult{name: name, resp: resp, err: err, latency: time.Since(startTime)}
+
+			if err == nil {
+				if commands, err := commandExtractor.Extract(resp.Text); err == nil {
+					for _, command := range commands.GetAllCommands() {
+						result.commands = append(result.commands, strings.TrimSpace(command.Command))
+					}
+				}
+			}
+
+			results[i] = result
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// compareStats summarizes the latency and token counts of an answer
+func compareStats(result compareResult) string {
+	if result.err != nil {
+		return fmt.Sprintf("failed after %s", formatLatency(result.latency))
+	}
+
+	stats := []string{result.resp.Model, formatLatency(result.latency)}
+	if result.resp.Cached {
+		stats = append(stats, "cached")
+	} else {
+		stats = append(stats, fmt.Sprintf("%d in / %d out", result.resp.InputTokens, result.resp.OutputTokens))
+	}
+
+	return strings.Join(stats, " · ")
+}
+
+func formatLatency(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 1, 64) + "s"
+}
+
+// displaySideBySide shows the answers in columns filling the terminal
+func displaySideBySide(results []compareResult, width int) {
+	colWidth := columnWidth(width, len(results))
+	bold := color.New(color.Bold).Sprint
+	failed := color.New(color.FgRed).Sprint
+
+	var header, stats, rule []string
+	columns := make([][]string, len(results))
+	height := 0
+
+	for i, result := range results {
+		header = append(header, bold(pad(truncate(result.name, colWidth), colWidth)))
+		stats = append(stats, dim(pad(truncate(compareStats(result), colWidth), colWidth)))
+		rule = append(rule, strings.Repeat("─", colWidth))
+
+		if result.err != nil {
+			for _, line := range wrapText(result.err.Error(), colWidth) {
+				columns[i] = append(columns[i], failed(pad(line, colWidth)))
+			}
+		} else {
+			for _, line := range wrapText(strings.TrimSpace(result.resp.Text), colWidth) {
+				columns[i] = append(columns[i], pad(line, colWidth))
+			}
+		}
+
+		if len(columns[i]) > height {
+			height = len(columns[i])
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(strings.Join(header, columnSeparator))
+	fmt.Println(strings.Join(stats, columnSeparator))
+	fmt.Println(dim(strings.Join(rule, "─┼─")))
+
+	blank := strings.Repeat(" ", colWidth)
+	for row := 0; row < height; row++ {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = blank
+			if row < len(column) {
+				cells[i] = column[row]
+			}
+		}
+		fmt.Println(strings.TrimRight(strings.Join(cells, columnSeparator), " "))
+	}
+}
+
+// displaySequential shows the answers one after another, formatted like a
+// single answer, for terminals too narrow for columns
+func displaySequential(results []compareResult) {
+	terminalFormatter := text.NewTerminalFormatter(text.CompactConfig())
+
+	for _, result := range results {
+		fmt.Println()
+		fmt.Printf("%s %s\n", color.New(color.Bold).Sprintf("━━ %s ━━", result.name), dim(compareStats(result)))
+
+		if result.err != nil {
+			fmt.Println(color.RedString("Error: %v", result.err))
+			continue
+		}
+
+		fmt.Print(terminalFormatter.Format(result.resp.Text))
+		fmt.Println()
+	}
+}
+
+// displayCommandComparison lists every suggested command with the providers
+// that suggested it, so that agreements and differences stand out
+func displayCommandComparison(results []compareResult) {
+	var commands []string
+	suggestedBy := make(map[string]map[string]bool)
+	for _, result := range results {
+		for _, command := range result.commands {
+			if suggestedBy[command] == nil {
+				suggestedBy[command] = make(map[string]bool)
+				commands = append(commands, command)
+			}
+			suggestedBy[command][result.name] = true
+		}
+	}
+
+	if len(commands) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Suggested commands:")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, " ")
+	for _, result := range results {
+		fmt.Fprintf(w, "\t%s", result.name)
+	}
+	fmt.Fprintln(w)
+
+	for _, command := range commands {
+		firstLine, _, _ := strings.Cut(command, "\n")
+		fmt.Fprintf(w, "  %s %s", color.CyanString("$"), truncate(firstLine, maxCommandWidth))
+		for _, result := range results {
+			mark := dim("·")
+			if suggestedBy[command][result.name] {
+				mark = color.GreenString("✓")
+			}
+			fmt.Fprintf(w, "\t%s", mark)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// terminalWidth returns the width of the terminal, or of COLUMNS when the
+// output is not a terminal
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
+
+// columnWidth returns the width of each of n columns filling width
+func columnWidth(width, n int) int {
+	if n == 0 {
+		return width
+	}
+	return (width - utf8.RuneCountInString(columnSeparator)*(n-1)) / n
+}
+
+// wrapText breaks text into lines of at most width characters, at spaces
+// where possible
+func wrapText(s string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\t", "    "), "\n") {
+		line = strings.TrimRight(line, " ")
+		for utf8.RuneCountInString(line) > width {
+			runes := []rune(line)
+			cut := width
+			for i := width; i > width/2; i-- {
+				if runes[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+			line = strings.TrimLeft(string(runes[cut:]), " ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pad fills s with spaces up to width characters
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// truncate shortens s to at most width characters, marking the cut
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
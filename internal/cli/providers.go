@@ -1,13 +1,25 @@
 package cli
 
 import (
+	gocontext "context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
 
+	"github.com/Codilas/how/internal/manager"
+	"github.com/Codilas/how/pkg/providers"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var (
+	testJSON    bool
+	testTimeout time.Duration
+)
+
 var providersCmd = &cobra.Command{
 	Use:   "providers",
 	Short: "Manage AI providers",
@@ -23,7 +35,10 @@ var listProvidersCmd = &cobra.Command{
 var testProvidersCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Test provider connectivity",
-	Run:   runTestProviders,
+	Long: `Check every provider with a cheap real call, reporting the latency, whether the
+credentials are accepted and whether the configured model is available. Exits
+with status 1 when any check fails.`,
+	Run: runTestProviders,
 }
 
 var capabilitiesCmd = &cobra.Command{
@@ -37,54 +52,163 @@ func init() {
 	providersCmd.AddCommand(listProvidersCmd)
 	providersCmd.AddCommand(testProvidersCmd)
 	providersCmd.AddCommand(capabilitiesCmd)
+
+	testProvidersCmd.Flags().BoolVar(&testJSON, "json", false, "print the results as JSON, for monitoring scripts")
+	testProvidersCmd.Flags().DurationVar(&testTimeout, "timeout", 10*time.Second, "time allowed for each provider")
 }
 
 func runListProviders(cmd *cobra.Command, args []string) {
-	infos := mng.ListProviders()
+	statuses := mng.Statuses()
 
-	if len(infos) == 0 {
+	if len(statuses) == 0 {
 		fmt.Println("No providers configured.")
 		fmt.Println("Run 'how setup' to configure a provider.")
 		return
 	}
 
-	fmt.Println("Available providers:")
+	fmt.Println("Configured providers:")
 	fmt.Println()
 
-	for _, info := range infos {
-		status := color.GreenString("✓")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tTYPE\tMODEL\tSTATUS")
 
-		// Check if provider is current
-		current := ""
-		if info.Type == cfg.CurrentProvider {
-			current = color.BlueString(" (current)")
+	failed := 0
+	for _, status := range statuses {
+		state := color.GreenString("✓") + " ready"
+		if status.Err != nil {
+			state = color.RedString("✗ ") + status.Err.Error()
+			failed++
 		}
 
-		// Fixed: Use color.New instead of color.BoldString
-		bold := color.New(color.Bold)
-		fmt.Printf("  %s %s%s\n", status, bold.Sprint(info.Name), current)
-		fmt.Printf("    Type: %s\n", info.Type)
-		fmt.Printf("    Model: %s\n", info.Model)
-		if info.Description != "" {
-			fmt.Printf("    Description: %s\n", info.Description)
+		// Check if provider is current
+		if status.Name == cfg.CurrentProvider {
+			state += color.BlueString(" (current)")
 		}
+
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", status.Name, status.Info.Type, status.Info.Model, state)
+	}
+	w.Flush()
+
+	if failed > 0 {
 		fmt.Println()
+		fmt.Printf("%d of %d providers failed to load; the others can still be used.\n", failed, len(statuses))
 	}
 }
 
+// healthResult is the result of checking a provider as printed by
+// providers test --json
+type healthResult struct {
+	Provider       string `json:"provider"`
+	Type           string `json:"type"`
+	Model          string `json:"model"`
+	Status         string `json:"status"`
+	Auth           string `json:"auth"`
+	ModelAvailable *bool  `json:"model_available"`
+	Live           bool   `json:"live"`
+	LatencyMS      int64  `json:"latency_ms"`
+	Error          string `json:"error,omitempty"`
+}
+
+func newHealthResult(health manager.Health) healthResult {
+	result := healthResult{
+		Provider:  health.Provider,
+		Type:      health.Info.Type,
+		Model:     health.Info.Model,
+		Status:    health.Status(),
+		Auth:      "unknown",
+		Live:      health.Live,
+		LatencyMS: health.Latency.Milliseconds(),
+	}
+
+	// Whether the credentials and the model are fine is only known once a
+	// call got that far
+	modelAvailable := false
+	switch result.Status {
+	case manager.HealthOK:
+		if health.Live {
+			result.Auth = "ok"
+			modelAvailable = true
+			result.ModelAvailable = &modelAvailable
+		}
+	case manager.HealthAuthFailed:
+		result.Auth = "failed"
+	case manager.HealthModelUnavailable:
+		result.Auth = "ok"
+		result.ModelAvailable = &modelAvailable
+	case manager.HealthRateLimited, manager.HealthQuotaExceeded:
+		result.Auth = "ok"
+	}
+
+	if health.Err != nil {
+		result.Error = health.Err.Error()
+	}
+
+	return result
+}
+
 func runTestProviders(cmd *cobra.Command, args []string) {
-	fmt.Println("Testing provider connectivity...")
-	fmt.Println()
+	if !testJSON {
+		fmt.Println("Testing provider connectivity...")
+		fmt.Println()
+	}
 
-	results := mng.HealthCheck()
+	ctx, stop := signal.NotifyContext(gocontext.Background(), os.Interrupt)
+	defer stop()
 
-	for name, err := range results {
-		if err == nil {
-			fmt.Printf("  %s %s\n", color.GreenString("✓"), name)
-		} else {
-			fmt.Printf("  %s %s: %v\n", color.RedString("✗"), name, err)
+	var results []healthResult
+	failed := 0
+	for _, health := range mng.HealthCheck(ctx, testTimeout) {
+		if health.Err != nil {
+			failed++
 		}
+		results = append(results, newHealthResult(health))
 	}
+
+	if testJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if results == nil {
+			results = []healthResult{}
+		}
+		if err := encoder.Encode(results); err != nil {
+			exitWithError(err)
+		}
+	} else {
+		displayHealth(results)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// displayHealth prints a table of the provider checks
+func displayHealth(results []healthResult) {
+	if len(results) == 0 {
+		fmt.Println("No providers configured.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tMODEL\tLATENCY\tAUTH\tSTATUS")
+
+	for _, result := range results {
+		latency := "-"
+		if result.Live {
+			latency = fmt.Sprintf("%dms", result.LatencyMS)
+		}
+
+		state := color.GreenString("✓ ") + result.Status
+		switch {
+		case result.Error != "":
+			state = color.RedString("✗ ") + result.Status + ": " + result.Error
+		case !result.Live:
+			state += dim(" (configuration only, no live check)")
+		}
+
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", result.Provider, result.Model, latency, result.Auth, state)
+	}
+	w.Flush()
 }
 
 func runCapabilities(cmd *cobra.Command, args []string) {
@@ -111,6 +235,12 @@ func runCapabilities(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("  Max Context Size: %s\n", formatNumber(caps.MaxContextSize))
 	fmt.Printf("  Max Tokens: %s\n", formatNumber(caps.MaxTokens))
+
+	if aiProvider, err := mng.GetProvider(providerName); err == nil {
+		if model, ok := providers.LookupModel(aiProvider.GetInfo().Model); ok && model.Priced() {
+			fmt.Printf("  Price: $%g / $%g per million input / output tokens\n", model.InputPrice, model.OutputPrice)
+		}
+	}
 }
 
 func showCapability(name string, supported bool) {
@@ -1,17 +1,31 @@
 package cli
 
 import (
+	gocontext "context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/Codilas/how/internal/cache"
 	"github.com/Codilas/how/internal/config"
 	"github.com/Codilas/how/internal/context"
 	"github.com/Codilas/how/internal/manager"
+	"github.com/Codilas/how/internal/ratelimit"
+	"github.com/Codilas/how/internal/usage"
 	"github.com/Codilas/how/pkg/extractor"
 	"github.com/Codilas/how/pkg/providers"
 	"github.com/Codilas/how/pkg/providers/anthropic"
+	"github.com/Codilas/how/pkg/providers/exec"
+	"github.com/Codilas/how/pkg/providers/gemini"
+	"github.com/Codilas/how/pkg/providers/mock"
+	"github.com/Codilas/how/pkg/providers/ollama"
+	"github.com/Codilas/how/pkg/providers/openai"
 	"github.com/Codilas/how/pkg/text"
+	"github.com/Codilas/how/pkg/tools"
 	"github.com/Codilas/how/pkg/version"
 	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
@@ -19,12 +33,17 @@ import (
 )
 
 var (
-	cfgFile   string
-	verbose   bool
-	useStream bool
-	provider  string
-	cfg       *config.Config
-	mng       *manager.Manager
+	cfgFile      string
+	verbose      bool
+	useStream    bool
+	provider     string
+	noCache      bool
+	noTools      bool
+	showThinking bool
+	images       []string
+	compareWith  []string
+	cfg          *config.Config
+	mng          *manager.Manager
 )
 
 var rootCmd = &cobra.Command{
@@ -46,6 +65,11 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&useStream, "stream", "s", false, "stream response")
 	rootCmd.PersistentFlags().StringVarP(&provider, "provider", "p", "", "AI provider to use")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "do not use or store cached responses")
+	rootCmd.Flags().BoolVar(&noTools, "no-tools", false, "do not let the AI inspect files or run read-only commands")
+	rootCmd.Flags().BoolVar(&showThinking, "show-thinking", false, "show the model's reasoning when extended thinking is enabled")
+	rootCmd.Flags().StringArrayVar(&images, "image", nil, "attach a PNG, JPEG, GIF or WebP image (repeatable)")
+	rootCmd.Flags().StringSliceVar(&compareWith, "compare", nil, "ask several providers at once and compare their answers (e.g. claude,gpt,local)")
 
 	// Add version flag
 	rootCmd.Flags().BoolP("version", "V", false, "show version")
@@ -55,11 +79,19 @@ func init() {
 	rootCmd.AddCommand(historyCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(providersCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(usageCmd)
 }
 
 func initConfig() {
 
 	manager.RegisterProvider(anthropic.ProviderName, anthropic.NewProvider)
+	manager.RegisterProvider(openai.ProviderName, openai.NewProvider)
+	manager.RegisterProvider(openai.CompatibleProviderName, openai.NewCompatibleProvider)
+	manager.RegisterProvider(ollama.ProviderName, ollama.NewProvider)
+	manager.RegisterProvider(gemini.ProviderName, gemini.NewProvider)
+	manager.RegisterProvider(mock.ProviderName, mock.NewProvider)
+	manager.RegisterProvider(exec.ProviderName, exec.NewProvider)
 
 	var err error
 	// Load configuration
@@ -71,60 +103,120 @@ func initConfig() {
 
 	// Initialize provider manager
 	mng = manager.NewManager()
+	manager.RegisterModels(cfg.Models)
 
-	// Ensure we have at least the mock provider for testing
-	// ensureDefaultProviders(cfg)
+	// Register the configured providers, which are loaded on first use
+	mng.LoadProviders(cfg.Providers)
 
-	// Load providers from config
-	if err := mng.LoadProviders(cfg.Providers); err != nil {
+	// Set up the fallback chain
+	if len(cfg.Fallback.Providers) > 0 {
+		var statePath string
+		if configDir, err := config.Dir(); err == nil {
+			statePath = filepath.Join(configDir, "circuit.json")
+		}
+
+		breaker := manager.NewCircuitBreaker(statePath,
+			cfg.Fallback.FailureThreshold, cfg.Fallback.FailureWindow, cfg.Fallback.Cooldown)
+		mng.SetFallback(cfg.Fallback.Providers, breaker)
+		mng.OnFallback(showFallback)
+	}
+
+	// Route questions to the provider suited to them
+	if cfg.Routing.Enabled {
+		if err := mng.SetRouting(cfg.Routing.Rules, cfg.Routing.PreferredTypes); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: routing disabled: %v\n", err)
+			cfg.Routing.Enabled = false
+		}
+	}
+
+	// Keep requests within the context window, trimming context if needed
+	mng.SetContextBudget(cfg.Context.MaxContextSize)
+	if verbose {
+		mng.OnContextTrimmed(showContextTrimmed)
+	}
+
+	// Serve repeated questions from the response cache
+	if !cfg.Cache.Disabled && !noCache {
+		if store, err := openCache(); err == nil {
+			mng.Use(func(name string, next providers.ProviderV2) providers.ProviderV2 {
+				return cache.Wrap(name, next, store, cfg.Cache.RecentCommands)
+			})
+		} else if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: response cache disabled: %v\n", err)
+		}
+	}
+
+	// Record the tokens and cost of every answer that was not cached
+	if ledger, err := openLedger(); err == nil {
+		mng.Use(func(name string, next providers.ProviderV2) providers.ProviderV2 {
+			return usage.Wrap(name, next, ledger, pricing(name))
+		})
+	}
+
+	// Queue requests within the providers' rate limits, which are shared by
+	// all invocations running at once
+	if configDir, err := config.Dir(); err == nil {
+		limiter := ratelimit.NewLimiter(filepath.Join(configDir, "ratelimit.json"))
+		var onWait func(string, *ratelimit.Permit)
 		if verbose {
-			fmt.Fprintf(os.Stderr, "Warning: failed to load some providers: %v\n", err)
+			onWait = showRateLimitWait
 		}
-		// Fallback: ensure mock provider is available
-		// if err := ensureMockProvider(); err != nil {
-		// 	fmt.Fprintf(os.Stderr, "Failed to load fallback mock provider: %v\n", err)
-		// }
-	}
-
-	// TODO: Figure out this mock stuff
-	// Verify we have at least one working provider
-	// if len(manager.ListProviders()) == 0 {
-	// 	if err := ensureMockProvider(); err != nil {
-	// 		fmt.Fprintf(os.Stderr, "No providers available and failed to create mock provider: %v\n", err)
-	// 		os.Exit(1)
-	// 	}
-	// }
+
+		mng.Use(func(name string, next providers.ProviderV2) providers.ProviderV2 {
+			limits := rateLimits(name)
+			if limits.Unlimited() {
+				return next
+			}
+			return ratelimit.Wrap(name, next, limiter, limits, onWait)
+		})
+	}
+}
+
+// showFallback tells the user that a provider was passed over for the next
+// one in the fallback chain
+func showFallback(event manager.FallbackEvent) {
+	if event.Err == nil {
+		fmt.Fprintln(os.Stderr, color.HiBlackString("Skipping %s: failing repeatedly, trying the next provider", event.Provider))
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, color.HiBlackString("%s failed (%v), trying the next provider", event.Provider, event.Err))
 }
 
-// ensureDefaultProviders makes sure we have basic providers configured
-func ensureDefaultProviders(cfg *config.Config) {
-	if cfg.Providers == nil {
-		cfg.Providers = make(map[string]config.ProviderConfig)
-	}
-
-	// Always ensure mock provider exists for testing
-	// if _, exists := cfg.Providers["mock"]; !exists {
-	// 	cfg.Providers["mock"] = config.ProviderConfig{
-	// 		Type:      "mock",
-	// 		Model:     "mock-model",
-	// 		MaxTokens: 1000,
-	// 	}
-	// }
-
-	// If no current provider is set and we have no real providers, default to mock
-	// if cfg.CurrentProvider == "" || (cfg.CurrentProvider != "mock" && !hasRealProvider(cfg.Providers)) {
-	// 	cfg.CurrentProvider = "mock"
-	// }
+// showContextTrimmed tells the user which context was left out to fit a
+// provider's context window
+func showContextTrimmed(trim manager.ContextTrim) {
+	fmt.Fprintln(os.Stderr, color.HiBlackString("Trimmed %s to fit %s (~%d of %d tokens)",
+		strings.Join(trim.Sections, ", "), trim.Provider, trim.Tokens, trim.Limit))
 }
 
-// hasRealProvider checks if we have any non-mock providers configured
-func hasRealProvider(providers map[string]config.ProviderConfig) bool {
-	for name, provider := range providers {
-		if name != "mock" && provider.APIKey != "" {
-			return true
-		}
+// rateLimits returns the configured rate limits of the named provider
+func rateLimits(name string) ratelimit.Limits {
+	rateLimit := cfg.Providers[name].RateLimit
+	if rateLimit == nil {
+		return ratelimit.Limits{}
 	}
-	return false
+
+	return ratelimit.Limits{
+		RequestsPerMinute: rateLimit.RequestsPerMinute,
+		TokensPerMinute:   rateLimit.TokensPerMinute,
+		MaxConcurrent:     rateLimit.MaxConcurrent,
+	}
+}
+
+// showRateLimitWait tells the user how long a request was queued by the
+// rate limits of its provider
+func showRateLimitWait(name string, permit *ratelimit.Permit) {
+	fmt.Fprintln(os.Stderr, color.HiBlackString("Waited %s for %s's %s limit",
+		permit.Waited.Round(100*time.Millisecond), name, permit.Reason))
+}
+
+// showRoute explains why a question was routed to a provider
+func showRoute(route manager.Route) {
+	for _, reason := range route.Reasons {
+		fmt.Fprintln(os.Stderr, color.HiBlackString("Routing: %s", reason))
+	}
+	fmt.Fprintln(os.Stderr, color.HiBlackString("Routed to %s", route.Provider))
 }
 
 func handlePrompt(cmd *cobra.Command, args []string) {
@@ -141,17 +233,36 @@ func handlePrompt(cmd *cobra.Command, args []string) {
 
 	prompt := strings.Join(args, " ")
 
+	// Ask several providers at once
+	if len(compareWith) > 0 {
+		handleComparePrompt(prompt, compareWith)
+		return
+	}
+
+	req := newRequest(prompt)
+
 	// Determine which provider to use
 	providerName := provider
 	if providerName == "" {
 		providerName = cfg.CurrentProvider
+
+		if cfg.Routing.Enabled {
+			route := mng.Route(req, providerName, useStream)
+			if verbose {
+				showRoute(route)
+			}
+			providerName = route.Provider
+		}
 	}
 
+	// Stay within the spending caps
+	providerName = applyBudget(providerName)
+
 	// Get the provider
 	aiProvider, err := mng.GetProvider(providerName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Available providers: %v\n", getProviderNames())
+		fmt.Fprintf(os.Stderr, "Available providers: %v\n", mng.Names())
 		os.Exit(1)
 	}
 
@@ -161,6 +272,22 @@ func handlePrompt(cmd *cobra.Command, args []string) {
 		fmt.Printf("Using %s (%s)\n", info.Name, info.Model)
 	}
 
+	// Cancel the request on Ctrl-C
+	reqCtx, stop := signal.NotifyContext(gocontext.Background(), os.Interrupt)
+	defer stop()
+
+	// Send prompt
+	if useStream {
+		handleStreamingPrompt(reqCtx, providerName, req)
+		return
+	}
+
+	handleRegularPrompt(reqCtx, providerName, req)
+}
+
+// newRequest builds the request for a prompt: the gathered context, the
+// attached images and the tools the model may use
+func newRequest(prompt string) *providers.Request {
 	// Gather context
 	ctx, err := context.Gather(cfg.Context)
 	if err != nil && verbose {
@@ -172,16 +299,32 @@ func handlePrompt(cmd *cobra.Command, args []string) {
 		showContext(ctx)
 	}
 
-	// Send prompt
-	if useStream {
-		handleStreamingPrompt(aiProvider, prompt, ctx)
-		return
+	req := providers.NewRequest(prompt, ctx)
+
+	// Attach images to the prompt
+	for _, path := range images {
+		image, err := providers.LoadImage(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		last := &req.Messages[len(req.Messages)-1]
+		last.Images = append(last.Images, image)
+	}
+
+	// Let the model inspect the working directory
+	if !cfg.Tools.Disabled && !noTools {
+		if wd, err := os.Getwd(); err == nil {
+			req.Tools = tools.Default(wd, cfg.Tools.AllowedCommands).Tools()
+			req.Options.MaxToolTurns = cfg.Tools.MaxTurns
+			req.OnToolCall = showToolCall
+		}
 	}
 
-	handleRegularPrompt(aiProvider, prompt, ctx)
+	return req
 }
 
-func handleRegularPrompt(aiProvider providers.Provider, prompt string, ctx *providers.Context) {
+func handleRegularPrompt(reqCtx gocontext.Context, providerName string, req *providers.Request) {
 	// Show spinner
 	s := spinner.New(spinner.CharSets[14], 100)
 	s.Suffix = " Thinking..."
@@ -190,52 +333,152 @@ func handleRegularPrompt(aiProvider providers.Provider, prompt string, ctx *prov
 	}
 	s.Start()
 
+	// Keep tool calls from mixing with the spinner
+	if req.OnToolCall != nil {
+		req.OnToolCall = func(call providers.ToolCall) {
+			s.Stop()
+			showToolCall(call)
+			s.Start()
+		}
+	}
+
 	// Send prompt
-	response, err := aiProvider.SendPrompt(prompt, ctx)
+	response, err := mng.Send(reqCtx, providerName, req)
 	s.Stop()
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exitWithError(err)
 	}
 
 	// Display response
 	displayResponse(response)
 }
 
-func handleStreamingPrompt(aiProvider providers.Provider, prompt string, ctx *providers.Context) {
-	fmt.Print("🤖 ")
+func handleStreamingPrompt(reqCtx gocontext.Context, providerName string, req *providers.Request) {
+	// Start tool calls on their own line
+	if req.OnToolCall != nil {
+		req.OnToolCall = func(call providers.ToolCall) {
+			fmt.Println()
+			showToolCall(call)
+		}
+	}
 
 	// Send streaming prompt
-	responseChan, err := aiProvider.SendPromptStream(prompt, ctx)
+	responseChan, err := mng.Stream(reqCtx, providerName, req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exitWithError(err)
 	}
 
-	// Handle streaming response
+	// Handle streaming response, which may start with the model's reasoning
 	var fullText strings.Builder
+	var metadata map[string]interface{}
+	section := ""
 	for chunk := range responseChan {
 		if chunk.Error != nil {
-			fmt.Fprintf(os.Stderr, "\nError: %v\n", chunk.Error)
-			os.Exit(1)
+			fmt.Println()
+			exitWithError(chunk.Error)
 		}
 
 		if chunk.Done {
+			metadata = chunk.Metadata
 			break
 		}
 
+		if chunk.Thinking != "" {
+			if section != "thinking" {
+				startSection(section, dim("💭 "))
+				if !showThinking {
+					fmt.Print(dim("Thinking... (--show-thinking to see the reasoning)"))
+				}
+				section = "thinking"
+			}
+			if showThinking {
+				fmt.Print(dim(chunk.Thinking))
+			}
+			continue
+		}
+
+		if section != "text" {
+			startSection(section, "🤖 ")
+			section = "text"
+		}
+
 		fmt.Print(chunk.Text)
 		fullText.WriteString(chunk.Text)
 	}
 
 	fmt.Println() // New line after streaming
 
-	// Show metadata if verbose
-	// TODO: ass more metadata
+	// The channel closes without a final chunk when the request is cancelled
+	if reqCtx.Err() != nil {
+		exitWithError(reqCtx.Err())
+	}
+
+	// Show metadata if verbose, for the provider that answered
 	if verbose {
-		fmt.Printf("\nResponse completed\n")
+		if name, ok := metadata[providers.MetadataProvider].(string); ok {
+			providerName = name
+		}
+		if aiProvider, err := mng.GetProvider(providerName); err == nil {
+			fmt.Printf("\n%s\n", color.HiBlackString(formatStreamMetadata(aiProvider.GetInfo(), metadata)))
+		}
+	}
+}
+
+// startSection starts a part of a streamed answer with prefix, separated
+// from the previous part, if any
+func startSection(previous, prefix string) {
+	if previous != "" {
+		fmt.Print("\n\n")
 	}
+	fmt.Print(prefix)
+}
+
+// dim formats text for secondary information such as the model's reasoning
+var dim = color.New(color.FgHiBlack).Sprint
+
+// displayThinking shows the model's reasoning dimmed, or collapsed to a
+// single line unless --show-thinking is set
+func displayThinking(reasoning string) {
+	if reasoning == "" {
+		return
+	}
+
+	fmt.Println()
+	if !showThinking {
+		fmt.Println(dim(fmt.Sprintf("💭 Reasoned for %d words (--show-thinking to see the reasoning)", len(strings.Fields(reasoning)))))
+		return
+	}
+	fmt.Println(dim("💭 " + reasoning))
+}
+
+// showToolCall shows a tool call made by the model
+func showToolCall(call providers.ToolCall) {
+	line := "🔧 " + tools.Describe(call)
+	if call.Err != nil {
+		message, _, _ := strings.Cut(call.Err.Error(), "\n")
+		line += " (failed: " + message + ")"
+	}
+
+	fmt.Fprintln(os.Stderr, color.HiBlackString(line))
+}
+
+// exitWithError reports a failed request and exits, using the conventional
+// status for an interrupt when the user cancelled it
+func exitWithError(err error) {
+	if errors.Is(err, gocontext.Canceled) {
+		fmt.Fprintln(os.Stderr, "Cancelled.")
+		os.Exit(130)
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if errors.Is(err, providers.ErrImagesNotSupported) {
+		fmt.Fprintln(os.Stderr, "Use --provider to pick a provider with image support, or set capabilities.imageAnalysis for a vision model.")
+	}
+	if errors.Is(err, providers.ErrImageTooLarge) {
+		fmt.Fprintln(os.Stderr, "Scale the image down, or use --provider to pick a provider that accepts larger images.")
+	}
+	os.Exit(1)
 }
 
 func displayResponse(resp *providers.Response) {
@@ -253,6 +496,8 @@ func displayResponse(resp *providers.Response) {
 
 	output := terminalFormatter.Format(resp.Text)
 
+	displayThinking(resp.Thinking)
+
 	fmt.Println()
 	fmt.Print(output)
 	fmt.Println()
@@ -300,18 +545,49 @@ func showContext(ctx *providers.Context) {
 }
 
 func formatMetadata(resp *providers.Response) string {
-	return fmt.Sprintf("Provider: %s | Model: %s | Tokens: %d | Time: %v",
+	if resp.Cached {
+		return fmt.Sprintf("Provider: %s | Model: %s | Cached", resp.Provider, resp.Model)
+	}
+
+	return fmt.Sprintf("Provider: %s | Model: %s | Tokens: %d%s | Time: %v",
 		resp.Provider,
 		resp.Model,
 		resp.TokensUsed,
+		formatPromptCache(resp.CacheReadTokens, resp.CacheWriteTokens),
 		resp.ResponseTime,
 	)
 }
 
-func getProviderNames() []string {
-	var names []string
-	for _, info := range mng.ListProviders() {
-		names = append(names, info.Type)
+// formatPromptCache describes the use of the provider's prompt cache, if any
+func formatPromptCache(readTokens, writeTokens int) string {
+	if readTokens == 0 && writeTokens == 0 {
+		return ""
 	}
-	return names
+	return fmt.Sprintf(" (cache: %d read, %d written)", readTokens, writeTokens)
+}
+
+func formatStreamMetadata(info providers.ProviderInfo, metadata map[string]interface{}) string {
+	model := info.Model
+	if m, ok := metadata[providers.MetadataModel].(string); ok && m != "" {
+		model = m
+	}
+
+	inputTokens, _ := metadata[providers.MetadataInputTokens].(int)
+	outputTokens, _ := metadata[providers.MetadataOutputTokens].(int)
+	stopReason, _ := metadata[providers.MetadataStopReason].(string)
+	cacheReadTokens, _ := metadata[providers.MetadataCacheReadTokens].(int)
+	cacheWriteTokens, _ := metadata[providers.MetadataCacheWriteTokens].(int)
+
+	if cached, _ := metadata[providers.MetadataCached].(bool); cached {
+		return fmt.Sprintf("Provider: %s | Model: %s | Cached", info.Type, model)
+	}
+
+	return fmt.Sprintf("Provider: %s | Model: %s | Tokens: %d in / %d out%s | Stop: %s",
+		info.Type,
+		model,
+		inputTokens,
+		outputTokens,
+		formatPromptCache(cacheReadTokens, cacheWriteTokens),
+		stopReason,
+	)
 }
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	gocontext "context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Codilas/how/internal/config"
+	"github.com/Codilas/how/internal/manager"
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/providers/mock"
+	"github.com/fatih/color"
+)
+
+// captureOutput returns what fn prints to the standard output
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	fn()
+	w.Close()
+	return <-output
+}
+
+// useMockProvider points the global manager at the mock provider answering
+// from testdata/fixtures.yaml
+func useMockProvider(t *testing.T) {
+	t.Helper()
+
+	previous, previousVerbose, previousNoColor := mng, verbose, color.NoColor
+	t.Cleanup(func() { mng, verbose, color.NoColor = previous, previousVerbose, previousNoColor })
+
+	manager.RegisterProvider(mock.ProviderName, mock.NewProvider)
+	mng = manager.NewManager()
+	mng.LoadProviders(map[string]config.ProviderConfig{
+		"mock": {Type: "mock", Fixtures: "testdata/fixtures.yaml"},
+	})
+	color.NoColor = true
+}
+
+func send(t *testing.T, prompt string) *providers.Response {
+	t.Helper()
+
+	resp, err := mng.Send(gocontext.Background(), "mock", providers.NewRequest(prompt, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDisplayResponseStructuredCommands(t *testing.T) {
+	useMockProvider(t)
+
+	output := captureOutput(t, func() { displayResponse(send(t, "how do I clean the build?")) })
+
+	if !strings.Contains(output, "Remove the build output, then rebuild.") {
+		t.Errorf("output lacks the answer:\n%s", output)
+	}
+	if strings.Contains(output, "structured_commands") || strings.Contains(output, `"commands"`) {
+		t.Errorf("output shows the structured commands section:\n%s", output)
+	}
+
+	_, suggested, found := strings.Cut(output, "Suggested commands:\n")
+	if !found {
+		t.Fatalf("output lacks the suggested commands:\n%s", output)
+	}
+	want := "   ⚠ $ rm -rf build\n    Delete the build directory\n  $ make\n    Rebuild the project\n"
+	if suggested != want {
+		t.Errorf("suggested commands =\n%q\nwant\n%q", suggested, want)
+	}
+}
+
+func TestDisplayResponseCodeBlocks(t *testing.T) {
+	useMockProvider(t)
+	verbose = true
+
+	output := captureOutput(t, func() { displayResponse(send(t, "is the disk full?")) })
+
+	// Commands from code blocks are not known to be safe
+	if !strings.Contains(output, "Suggested commands:\n   ⚠ $ df -h\n") {
+		t.Errorf("output lacks the command from the code block:\n%s", output)
+	}
+	if !strings.Contains(output, "Provider: mock | Model: fixture-model | Tokens: ") {
+		t.Errorf("verbose output lacks the metadata:\n%s", output)
+	}
+
+	// Answers without commands suggest none
+	output = captureOutput(t, func() { displayResponse(send(t, "what is the meaning of life?")) })
+	if !strings.Contains(output, "I don't know.") || strings.Contains(output, "Suggested commands") {
+		t.Errorf("output =\n%s\nwant the answer alone", output)
+	}
+}
+
+func TestHandleStreamingPrompt(t *testing.T) {
+	useMockProvider(t)
+	verbose = true
+
+	output := captureOutput(t, func() {
+		handleStreamingPrompt(gocontext.Background(), "mock", providers.NewRequest("is the disk full?", nil))
+	})
+
+	resp := send(t, "is the disk full?")
+	if !strings.HasPrefix(output, "🤖 "+resp.Text) {
+		t.Errorf("streamed output =\n%s\nwant the whole answer", output)
+	}
+	if !strings.Contains(output, "Provider: mock | Model: fixture-model | Tokens: 4 in / ") || !strings.Contains(output, "Stop: end_turn") {
+		t.Errorf("verbose output lacks the stream's metadata:\n%s", output)
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/Codilas/how/internal/config"
 	"github.com/Codilas/how/pkg/providers"
 	"github.com/Codilas/how/pkg/providers/anthropic"
+	"github.com/Codilas/how/pkg/providers/gemini"
+	"github.com/Codilas/how/pkg/providers/ollama"
+	"github.com/Codilas/how/pkg/providers/openai"
 	"github.com/spf13/cobra"
 )
 
@@ -26,7 +29,7 @@ func runSetup(cmd *cobra.Command, args []string) {
 	var provider string
 	providerPrompt := &survey.Select{
 		Message: "Which AI provider would you like to use?",
-		Options: []string{"Anthropic (Claude)", "OpenAI (GPT)", "Local Model"},
+		Options: []string{"Anthropic (Claude)", "OpenAI (GPT)", "Google (Gemini)", "Local Model", "OpenAI-compatible server"},
 		Default: "Anthropic (Claude)",
 	}
 	survey.AskOne(providerPrompt, &provider)
@@ -43,43 +46,124 @@ func runSetup(cmd *cobra.Command, args []string) {
 		}
 		survey.AskOne(apiKeyPrompt, &apiKey)
 
-		tmpProvider, err := anthropic.NewProvider(providers.Config{
-			APIKey: apiKey,
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing Anthropic provider: %v\n", err)
-			os.Exit(1)
+		model = chooseModel(anthropic.NewProvider, providers.Config{APIKey: apiKey}, "Choose Claude model:")
+
+		if cfg.Providers == nil {
+			cfg.Providers = make(map[string]config.ProviderConfig)
+		}
+		cfg.Providers[anthropic.ProviderName] = config.ProviderConfig{
+			Type:      anthropic.ProviderName,
+			APIKey:    apiKey,
+			Model:     model,
+			MaxTokens: 1000,
 		}
 
-		models, err := tmpProvider.GetModels()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error fetching models: %v\n", err)
-			os.Exit(1)
+	case "OpenAI (GPT)":
+		cfg.CurrentProvider = openai.ProviderName
+
+		apiKeyPrompt := &survey.Password{
+			Message: "Enter your OpenAI API key:",
+		}
+		survey.AskOne(apiKeyPrompt, &apiKey)
+
+		model = chooseModel(openai.NewProvider, providers.Config{APIKey: apiKey}, "Choose GPT model:")
+
+		if cfg.Providers == nil {
+			cfg.Providers = make(map[string]config.ProviderConfig)
+		}
+		cfg.Providers[openai.ProviderName] = config.ProviderConfig{
+			Type:      openai.ProviderName,
+			APIKey:    apiKey,
+			Model:     model,
+			MaxTokens: 1000,
 		}
 
-		modelPrompt := &survey.Select{
-			Message: "Choose Claude model:",
-			Options: models,
-			Default: models[0],
+	case "Google (Gemini)":
+		cfg.CurrentProvider = gemini.ProviderName
+
+		apiKeyPrompt := &survey.Password{
+			Message: "Enter your Google AI API key:",
 		}
-		survey.AskOne(modelPrompt, &model)
+		survey.AskOne(apiKeyPrompt, &apiKey)
+
+		model = chooseModel(gemini.NewProvider, providers.Config{APIKey: apiKey}, "Choose Gemini model:")
 
 		if cfg.Providers == nil {
 			cfg.Providers = make(map[string]config.ProviderConfig)
 		}
-		cfg.Providers[anthropic.ProviderName] = config.ProviderConfig{
-			Type:      anthropic.ProviderName,
+		cfg.Providers[gemini.ProviderName] = config.ProviderConfig{
+			Type:      gemini.ProviderName,
 			APIKey:    apiKey,
 			Model:     model,
 			MaxTokens: 1000,
 		}
 
-	case "OpenAI (GPT)":
-		fmt.Println("OpenAI provider is not yet implemented.")
-		os.Exit(1)
 	case "Local Model":
-		fmt.Println("Local model provider is not yet implemented.")
-		os.Exit(1)
+		cfg.CurrentProvider = ollama.ProviderName
+
+		var serverURL string
+		serverPrompt := &survey.Input{
+			Message: "Ollama server URL:",
+			Default: "http://localhost:11434",
+		}
+		survey.AskOne(serverPrompt, &serverURL)
+
+		model = chooseModel(ollama.NewProvider, providers.Config{BaseURL: serverURL}, "Choose local model:")
+
+		if cfg.Providers == nil {
+			cfg.Providers = make(map[string]config.ProviderConfig)
+		}
+		cfg.Providers[ollama.ProviderName] = config.ProviderConfig{
+			Type:      ollama.ProviderName,
+			Model:     model,
+			BaseURL:   serverURL,
+			MaxTokens: 1000,
+		}
+
+	case "OpenAI-compatible server":
+		cfg.CurrentProvider = openai.CompatibleProviderName
+
+		var serverURL string
+		serverPrompt := &survey.Input{
+			Message: "Server base URL (e.g. http://localhost:8080/v1):",
+		}
+		survey.AskOne(serverPrompt, &serverURL)
+
+		apiKeyPrompt := &survey.Password{
+			Message: "Enter the API key (leave empty if none):",
+		}
+		survey.AskOne(apiKeyPrompt, &apiKey)
+
+		// Not every server lists its models, so fall back to asking for the name
+		tmpProvider, err := openai.NewCompatibleProvider(providers.Config{BaseURL: serverURL, APIKey: apiKey})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing provider: %v\n", err)
+			os.Exit(1)
+		}
+		if models, err := tmpProvider.GetModels(); err == nil && len(models) > 0 {
+			modelPrompt := &survey.Select{
+				Message: "Choose model:",
+				Options: models,
+				Default: models[0],
+			}
+			survey.AskOne(modelPrompt, &model)
+		} else {
+			modelPrompt := &survey.Input{
+				Message: "Model name:",
+			}
+			survey.AskOne(modelPrompt, &model)
+		}
+
+		if cfg.Providers == nil {
+			cfg.Providers = make(map[string]config.ProviderConfig)
+		}
+		cfg.Providers[openai.CompatibleProviderName] = config.ProviderConfig{
+			Type:      openai.CompatibleProviderName,
+			APIKey:    apiKey,
+			Model:     model,
+			BaseURL:   serverURL,
+			MaxTokens: 1000,
+		}
 	}
 
 	// Context preferences
@@ -142,3 +226,33 @@ func runSetup(cmd *cobra.Command, args []string) {
 	fmt.Println("• Try: how \"how to use grep?\"")
 	fmt.Println("• Try: how \"write a Python function to reverse a string\"")
 }
+
+// chooseModel fetches the available models from a provider and asks the user to pick one
+func chooseModel(constructor func(providers.Config) (providers.Provider, error), providerCfg providers.Config, message string) string {
+	tmpProvider, err := constructor(providerCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing provider: %v\n", err)
+		os.Exit(1)
+	}
+
+	models, err := tmpProvider.GetModels()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching models: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(models) == 0 {
+		fmt.Fprintln(os.Stderr, "Error fetching models: no models available")
+		os.Exit(1)
+	}
+
+	var model string
+	modelPrompt := &survey.Select{
+		Message: message,
+		Options: models,
+		Default: models[0],
+	}
+	survey.AskOne(modelPrompt, &model)
+
+	return model
+}
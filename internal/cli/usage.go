@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Codilas/how/internal/config"
+	"github.com/Codilas/how/internal/usage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usageBy   string
+	usageDays int
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and cost",
+	Long:  `Show the tokens used and their cost, broken down by day, week, model or provider, and the spending caps.`,
+	Run:   runUsage,
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageBy, "by", "day", "breakdown: day, week, model or provider")
+	usageCmd.Flags().IntVar(&usageDays, "days", 30, "number of days to include")
+}
+
+// usageRow is the usage of one group of a breakdown
+type usageRow struct {
+	key          string
+	requests     int
+	inputTokens  int
+	outputTokens int
+	cost         float64
+	unpriced     bool
+}
+
+func runUsage(cmd *cobra.Command, args []string) {
+	groupKey, ok := usageGroups[usageBy]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown breakdown %q, use day, week, model or provider\n", usageBy)
+		os.Exit(1)
+	}
+
+	ledger, err := openLedger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	since := usage.StartOfDay(now).AddDate(0, 0, 1-usageDays)
+	if monthStart := usage.StartOfMonth(now); monthStart.Before(since) {
+		since = monthStart
+	}
+
+	records, err := ledger.Records(since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading usage: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Spending against the caps
+	var today, month float64
+	for _, record := range records {
+		if !record.Time.Before(usage.StartOfMonth(now)) {
+			month += record.Cost
+		}
+		if !record.Time.Before(usage.StartOfDay(now)) {
+			today += record.Cost
+		}
+	}
+	fmt.Printf("Today:      %s\n", formatSpent(today, cfg.Budget.Daily))
+	fmt.Printf("This month: %s\n", formatSpent(month, cfg.Budget.Monthly))
+	fmt.Println()
+
+	// Breakdown over the requested days
+	rows := map[string]*usageRow{}
+	periodStart := usage.StartOfDay(now).AddDate(0, 0, 1-usageDays)
+	for _, record := range records {
+		if record.Time.Before(periodStart) {
+			continue
+		}
+
+		key := groupKey(record)
+		row, ok := rows[key]
+		if !ok {
+			row = &usageRow{key: key}
+			rows[key] = row
+		}
+		row.requests++
+		row.inputTokens += record.InputTokens
+		row.outputTokens += record.OutputTokens
+		row.cost += record.Cost
+		row.unpriced = row.unpriced || record.Unpriced
+	}
+
+	if len(rows) == 0 {
+		fmt.Printf("No usage recorded in the last %d days.\n", usageDays)
+		return
+	}
+
+	sorted := make([]*usageRow, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		// Periods in order, models and providers by cost
+		if usageBy == "day" || usageBy == "week" {
+			return sorted[i].key < sorted[j].key
+		}
+		return sorted[i].cost > sorted[j].cost
+	})
+
+	var total usageRow
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "%s\tREQUESTS\tINPUT\tOUTPUT\tCOST\n", map[string]string{
+		"day": "DAY", "week": "WEEK", "model": "MODEL", "provider": "PROVIDER",
+	}[usageBy])
+	for _, row := range sorted {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", row.key, row.requests,
+			formatNumber(row.inputTokens), formatNumber(row.outputTokens), formatCost(row.cost, row.unpriced))
+
+		total.requests += row.requests
+		total.inputTokens += row.inputTokens
+		total.outputTokens += row.outputTokens
+		total.cost += row.cost
+		total.unpriced = total.unpriced || row.unpriced
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%s\t%s\t%s\n", total.requests,
+		formatNumber(total.inputTokens), formatNumber(total.outputTokens), formatCost(total.cost, total.unpriced))
+	w.Flush()
+
+	if total.unpriced {
+		fmt.Println()
+		fmt.Println(color.HiBlackString("* includes models without a known price; set pricing in the provider configuration"))
+	}
+}
+
+// usageGroups map a breakdown name to the key grouping records
+var usageGroups = map[string]func(usage.Record) string{
+	"day": func(r usage.Record) string {
+		return r.Time.Local().Format(time.DateOnly)
+	},
+	"week": func(r usage.Record) string {
+		year, week := r.Time.Local().ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+	"model": func(r usage.Record) string {
+		return r.Model
+	},
+	"provider": func(r usage.Record) string {
+		return r.Provider
+	},
+}
+
+// formatCost formats a cost in US dollars, marking totals that leave out
+// unpriced requests
+func formatCost(cost float64, unpriced bool) string {
+	formatted := fmt.Sprintf("$%.4f", cost)
+	if cost >= 1 {
+		formatted = fmt.Sprintf("$%.2f", cost)
+	}
+	if unpriced {
+		formatted += "*"
+	}
+	return formatted
+}
+
+// formatSpent formats spending against a cap, which may be unset
+func formatSpent(spent, limit float64) string {
+	if limit <= 0 {
+		return fmt.Sprintf("$%.2f", spent)
+	}
+
+	formatted := fmt.Sprintf("$%.2f of $%.2f cap", spent, limit)
+	if spent >= limit {
+		return color.RedString(formatted)
+	}
+	return formatted
+}
+
+// openLedger opens the usage ledger in the configuration directory
+func openLedger() (*usage.Ledger, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate usage ledger: %w", err)
+	}
+	return usage.NewLedger(filepath.Join(configDir, "usage.jsonl")), nil
+}
+
+// pricing returns the configured price override of a provider, if any
+func pricing(name string) *usage.Price {
+	providerCfg, ok := cfg.Providers[name]
+	if !ok || providerCfg.Pricing == nil {
+		return nil
+	}
+	return &usage.Price{Input: providerCfg.Pricing.Input, Output: providerCfg.Pricing.Output}
+}
+
+// applyBudget returns the provider to use for a request to providerName
+// given the spending caps: providerName itself, or the configured cheaper
+// provider once a cap is reached. It exits when a cap is reached and there
+// is nothing to downgrade to.
+func applyBudget(providerName string) string {
+	ledger, err := openLedger()
+	if err != nil {
+		return providerName
+	}
+
+	budget := usage.Budget{Daily: cfg.Budget.Daily, Monthly: cfg.Budget.Monthly}
+	err = budget.Check(ledger, time.Now())
+	if err == nil {
+		return providerName
+	}
+
+	if !errors.Is(err, usage.ErrBudgetExceeded) {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Warning: failed to check spending caps: %v\n", err)
+		}
+		return providerName
+	}
+
+	downgrade := cfg.Budget.DowngradeTo
+	if downgrade == "" {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Raise the cap in the budget configuration, or set budget.downgradeTo to a cheaper provider.")
+		os.Exit(1)
+	}
+
+	if downgrade != providerName {
+		fmt.Fprintln(os.Stderr, color.HiBlackString("%v; using %s", err, downgrade))
+	}
+	return downgrade
+}
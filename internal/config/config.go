@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -15,6 +16,16 @@ type Config struct {
 	Context         ContextConfig             `yaml:"context"`
 	Display         DisplayConfig             `yaml:"display"`
 	History         HistoryConfig             `yaml:"history"`
+	Fallback        FallbackConfig            `yaml:"fallback,omitempty"`
+	Cache           CacheConfig               `yaml:"cache,omitempty"`
+	Tools           ToolsConfig               `yaml:"tools,omitempty"`
+	Budget          BudgetConfig              `yaml:"budget,omitempty"`
+	Routing         RoutingConfig             `yaml:"routing,omitempty"`
+
+	// Limits, features and prices of models, by model name or prefix,
+	// adding to or correcting the built-in model registry. Decoded by
+	// loadModels rather than viper, which splits keys on dots.
+	Models map[string]ModelConfig `yaml:"models,omitempty" mapstructure:"-"`
 }
 
 type ProviderConfig struct {
@@ -25,10 +36,88 @@ type ProviderConfig struct {
 	MaxTokens int    `yaml:"maxTokens"`
 
 	// Additional provider-specific settings
-	Temperature   float32           `yaml:"temperature,omitempty"`
-	TopP          float32           `yaml:"topP,omitempty"`
+	Temperature   *float32          `yaml:"temperature,omitempty"`
+	TopP          *float32          `yaml:"topP,omitempty"`
 	SystemPrompt  string            `yaml:"systemPrompt,omitempty"`
 	CustomHeaders map[string]string `yaml:"customHeaders,omitempty"`
+
+	// Tokens of extended thinking before answering, on providers that
+	// support it; zero disables thinking
+	ThinkingBudget int `yaml:"thinkingBudget,omitempty"`
+
+	// Turns off prompt caching on providers that support it
+	DisablePromptCaching bool `yaml:"disablePromptCaching,omitempty"`
+
+	// Declared capabilities for servers that cannot report them
+	Capabilities *CapabilitiesConfig `yaml:"capabilities,omitempty"`
+
+	// Fixture file for the mock provider
+	Fixtures string `yaml:"fixtures,omitempty"`
+
+	// Plugin executable and arguments for the exec provider
+	Command string   `yaml:"command,omitempty"`
+	Args    []string `yaml:"args,omitempty"`
+
+	// How long to wait for an answer, and how to connect
+	Timeout time.Duration  `yaml:"timeout,omitempty"`
+	Network *NetworkConfig `yaml:"network,omitempty"`
+
+	// Retry policy for transient failures
+	Retry *RetryConfig `yaml:"retry,omitempty"`
+
+	// Client-side limits shared by all invocations of the command
+	RateLimit *RateLimitConfig `yaml:"rateLimit,omitempty"`
+
+	// Prices for models missing from the built-in price list
+	Pricing *PricingConfig `yaml:"pricing,omitempty"`
+}
+
+// ModelConfig overrides the registry entry of a model. Unset fields keep
+// their registry values.
+type ModelConfig struct {
+	ContextWindow   int            `yaml:"contextWindow,omitempty"`
+	MaxOutputTokens int            `yaml:"maxOutputTokens,omitempty"`
+	Vision          *bool          `yaml:"vision,omitempty"`
+	Tools           *bool          `yaml:"tools,omitempty"`
+	Pricing         *PricingConfig `yaml:"pricing,omitempty"`
+}
+
+// PricingConfig is the price of a model in US dollars per million tokens
+type PricingConfig struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// NetworkConfig holds the connection settings of HTTP-based providers. The
+// proxy defaults to the HTTPS_PROXY and NO_PROXY environment variables.
+type NetworkConfig struct {
+	ConnectTimeout time.Duration `yaml:"connectTimeout,omitempty"`
+	Proxy          string        `yaml:"proxy,omitempty"`
+	CAFile         string        `yaml:"caFile,omitempty"`
+	ClientCert     string        `yaml:"clientCert,omitempty"`
+	ClientKey      string        `yaml:"clientKey,omitempty"`
+	TLSMinVersion  string        `yaml:"tlsMinVersion,omitempty"`
+}
+
+type RetryConfig struct {
+	MaxRetries     *int          `yaml:"maxRetries,omitempty"`
+	InitialBackoff time.Duration `yaml:"initialBackoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff,omitempty"`
+}
+
+// RateLimitConfig limits the requests and tokens sent to a provider per
+// minute, and the requests in flight at once. Zero means unlimited.
+type RateLimitConfig struct {
+	RequestsPerMinute int `yaml:"requestsPerMinute,omitempty"`
+	TokensPerMinute   int `yaml:"tokensPerMinute,omitempty"`
+	MaxConcurrent     int `yaml:"maxConcurrent,omitempty"`
+}
+
+type CapabilitiesConfig struct {
+	Streaming      *bool `yaml:"streaming,omitempty"`
+	ImageAnalysis  *bool `yaml:"imageAnalysis,omitempty"`
+	MaxContextSize int   `yaml:"maxContextSize,omitempty"`
+	MaxTokens      int   `yaml:"maxTokens,omitempty"`
 }
 
 type ContextConfig struct {
@@ -53,6 +142,76 @@ type HistoryConfig struct {
 	FilePath string `yaml:"filePath"`
 }
 
+// CacheConfig controls the on-disk response cache, which is enabled unless
+// disabled here or with --no-cache
+type CacheConfig struct {
+	Disabled  bool          `yaml:"disabled,omitempty"`
+	TTL       time.Duration `yaml:"ttl,omitempty"`
+	MaxSizeMB int           `yaml:"maxSizeMB,omitempty"`
+	Dir       string        `yaml:"dir,omitempty"`
+
+	// Keys answers on the recent commands too, so that answers about the
+	// output of a command are not reused after other commands
+	RecentCommands bool `yaml:"recentCommands,omitempty"`
+}
+
+// ToolsConfig controls the read-only tools offered to providers that
+// support function calling
+type ToolsConfig struct {
+	Disabled        bool     `yaml:"disabled,omitempty"`
+	MaxTurns        int      `yaml:"maxTurns,omitempty"`
+	AllowedCommands []string `yaml:"allowedCommands,omitempty"`
+}
+
+// BudgetConfig caps spending in US dollars. Once a cap is reached, requests
+// go to the DowngradeTo provider, or are refused when it is not set.
+type BudgetConfig struct {
+	Daily       float64 `yaml:"daily,omitempty"`
+	Monthly     float64 `yaml:"monthly,omitempty"`
+	DowngradeTo string  `yaml:"downgradeTo,omitempty"`
+}
+
+// RoutingConfig picks the provider of each question not given one with
+// --provider. Rules are tried in order; when none applies, the provider
+// best meeting the needs of the question is chosen.
+type RoutingConfig struct {
+	Enabled bool          `yaml:"enabled,omitempty"`
+	Rules   []RoutingRule `yaml:"rules,omitempty"`
+
+	// Provider types preferred among those meeting the needs of a question
+	PreferredTypes []string `yaml:"preferredTypes,omitempty"`
+}
+
+// RoutingRule sends the questions meeting all of its conditions to
+// Provider. A rule without conditions matches every question.
+type RoutingRule struct {
+	Name     string `yaml:"name,omitempty"`
+	Provider string `yaml:"provider"`
+
+	// Match is a regular expression the prompt must match
+	Match string `yaml:"match,omitempty"`
+
+	// Estimated tokens of the prompt alone, and of the whole request
+	// including the gathered context
+	MaxPromptTokens  int `yaml:"maxPromptTokens,omitempty"`
+	MinContextTokens int `yaml:"minContextTokens,omitempty"`
+
+	// Whether images are attached
+	Images *bool `yaml:"images,omitempty"`
+}
+
+// FallbackConfig defines the providers tried, in order, when a provider is
+// unavailable, and when a repeatedly failing provider is skipped
+type FallbackConfig struct {
+	Providers []string `yaml:"providers,omitempty"`
+
+	// A provider that fails FailureThreshold times within FailureWindow is
+	// skipped for Cooldown
+	FailureThreshold int           `yaml:"failureThreshold,omitempty"`
+	FailureWindow    time.Duration `yaml:"failureWindow,omitempty"`
+	Cooldown         time.Duration `yaml:"cooldown,omitempty"`
+}
+
 func Load(configFile string) (*Config, error) {
 	// Set up viper
 	v := viper.New()
@@ -61,7 +220,7 @@ func Load(configFile string) (*Config, error) {
 	if configFile != "" {
 		v.SetConfigFile(configFile)
 	} else {
-		configDir, err := getConfigDir()
+		configDir, err := Dir()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get config directory: %w", err)
 		}
@@ -88,12 +247,41 @@ func Load(configFile string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if path := v.ConfigFileUsed(); path != "" {
+		models, err := loadModels(path)
+		if err != nil {
+			return nil, err
+		}
+		config.Models = models
+	}
+
 	return &config, nil
 }
 
+// loadModels decodes the models section of a config file. Model names such
+// as gpt-4.1 contain dots, which viper would split into nested keys.
+func loadModels(path string) (map[string]ModelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var section struct {
+		Models map[string]ModelConfig `yaml:"models"`
+	}
+	if err := yaml.Unmarshal(data, &section); err != nil {
+		return nil, fmt.Errorf("failed to parse models: %w", err)
+	}
+
+	return section.Models, nil
+}
+
 func (c *Config) Save(configFile string) error {
 	if configFile == "" {
-		configDir, err := getConfigDir()
+		configDir, err := Dir()
 		if err != nil {
 			return fmt.Errorf("failed to get config directory: %w", err)
 		}
@@ -119,7 +307,8 @@ func (c *Config) Save(configFile string) error {
 	return nil
 }
 
-func getConfigDir() (string, error) {
+// Dir returns the directory holding the configuration and state files
+func Dir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadModelsWithDots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `currentProvider: openai
+providers:
+  openai:
+    type: openai
+    model: gpt-4.1
+models:
+  gpt-4.1:
+    contextWindow: 1047576
+    maxOutputTokens: 32768
+  gemini-2.5-pro:
+    vision: true
+    pricing: { input: 1.25, output: 10 }
+  ft:gpt-4o-mini:acme:
+    contextWindow: 128000
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.Models) != 3 {
+		t.Fatalf("models = %v, want the three configured ones", cfg.Models)
+	}
+	if m := cfg.Models["gpt-4.1"]; m.ContextWindow != 1047576 || m.MaxOutputTokens != 32768 {
+		t.Errorf("gpt-4.1 = %+v", m)
+	}
+	if m := cfg.Models["gemini-2.5-pro"]; m.Vision == nil || !*m.Vision || m.Pricing == nil || m.Pricing.Output != 10 {
+		t.Errorf("gemini-2.5-pro = %+v", m)
+	}
+	if m := cfg.Models["ft:gpt-4o-mini:acme"]; m.ContextWindow != 128000 {
+		t.Errorf("ft:gpt-4o-mini:acme = %+v", m)
+	}
+
+	// The rest of the file is still read by viper
+	if cfg.Providers["openai"].Model != "gpt-4.1" {
+		t.Errorf("providers = %+v", cfg.Providers)
+	}
+}
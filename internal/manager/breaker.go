@@ -0,0 +1,169 @@
+package manager
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Codilas/how/internal/filelock"
+)
+
+// Circuit breaker defaults
+const (
+	defaultFailureThreshold = 3
+	defaultFailureWindow    = 5 * time.Minute
+	defaultCooldown         = 2 * time.Minute
+)
+
+// CircuitBreaker tracks provider failures and skips providers that failed
+// repeatedly. Its state is persisted to a file, so that it carries over
+// between invocations of the command.
+type CircuitBreaker struct {
+	path      string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	// mu serializes the updates of this process, and guards memory
+	mu     sync.Mutex
+	memory map[string]*circuit
+}
+
+// circuit is the failure record of a single provider. Trial is when the
+// provider was allowed again after its cooldown.
+type circuit struct {
+	Failures  []time.Time `json:"failures,omitempty"`
+	OpenUntil time.Time   `json:"open_until"`
+	Trial     time.Time   `json:"trial,omitempty"`
+}
+
+// NewCircuitBreaker creates a circuit breaker persisted at path. An empty
+// path keeps the state in memory; zero settings use the defaults.
+func NewCircuitBreaker(path string, threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if window <= 0 {
+		window = defaultFailureWindow
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+
+	return &CircuitBreaker{
+		path:      path,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		memory:    make(map[string]*circuit),
+	}
+}
+
+// Allow reports whether the named provider may be tried. A provider whose
+// cooldown has passed is allowed again on trial: a failure within the
+// failure window reopens it.
+func (b *CircuitBreaker) Allow(name string) bool {
+	allowed := true
+	b.update(func(state map[string]*circuit) bool {
+		now := time.Now()
+
+		c := state[name]
+		if c == nil || c.OpenUntil.IsZero() {
+			return false
+		}
+		if now.Before(c.OpenUntil) {
+			allowed = false
+			return false
+		}
+
+		c.OpenUntil = time.Time{}
+		c.Trial = now
+		return true
+	})
+	return allowed
+}
+
+// OpenUntil returns when the named provider will be tried again, or the
+// zero time if it is not being skipped
+func (b *CircuitBreaker) OpenUntil(name string) time.Time {
+	var openUntil time.Time
+	b.update(func(state map[string]*circuit) bool {
+		if c := state[name]; c != nil && time.Now().Before(c.OpenUntil) {
+			openUntil = c.OpenUntil
+		}
+		return false
+	})
+	return openUntil
+}
+
+// RecordFailure records a failed request to the named provider, opening its
+// circuit once the failure threshold is reached
+func (b *CircuitBreaker) RecordFailure(name string) {
+	b.update(func(state map[string]*circuit) bool {
+		now := time.Now()
+
+		c := state[name]
+		if c == nil {
+			c = &circuit{}
+			state[name] = c
+		}
+
+		// Forget failures that fell out of the window
+		var recent []time.Time
+		for _, t := range c.Failures {
+			if now.Sub(t) < b.window {
+				recent = append(recent, t)
+			}
+		}
+		c.Failures = append(recent, now)
+
+		// A provider that fails again right after its cooldown is reopened
+		// without waiting for the threshold
+		onTrial := !c.Trial.IsZero() && now.Sub(c.Trial) < b.window
+		if len(c.Failures) >= b.threshold || onTrial {
+			c.OpenUntil = now.Add(b.cooldown)
+		}
+		c.Trial = time.Time{}
+
+		return true
+	})
+}
+
+// RecordSuccess closes the circuit of the named provider
+func (b *CircuitBreaker) RecordSuccess(name string) {
+	b.update(func(state map[string]*circuit) bool {
+		if _, exists := state[name]; !exists {
+			return false
+		}
+
+		delete(state, name)
+		return true
+	})
+}
+
+// update applies fn to the state, saving it when fn reports a change. The
+// persisted state is read and written while holding the lock on its file,
+// so that concurrent invocations do not lose each other's failures. A
+// missing or unreadable file is treated as no failures, since the breaker
+// must never stop requests on its own.
+func (b *CircuitBreaker) update(fn func(state map[string]*circuit) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.path == "" {
+		fn(b.memory)
+		return
+	}
+
+	filelock.Update(b.path, func(data []byte) ([]byte, error) {
+		state := make(map[string]*circuit)
+		if len(data) > 0 && json.Unmarshal(data, &state) != nil {
+			state = make(map[string]*circuit)
+		}
+
+		if !fn(state) {
+			return nil, nil
+		}
+		return json.Marshal(state)
+	})
+}
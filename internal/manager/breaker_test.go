@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAndCloses(t *testing.T) {
+	for _, path := range []string{"", filepath.Join(t.TempDir(), "circuit.json")} {
+		b := NewCircuitBreaker(path, 2, time.Minute, time.Minute)
+
+		b.RecordFailure("claude")
+		if !b.Allow("claude") {
+			t.Fatal("circuit opened below the threshold")
+		}
+
+		b.RecordFailure("claude")
+		if b.Allow("claude") {
+			t.Fatal("circuit still closed at the threshold")
+		}
+		if b.OpenUntil("claude").IsZero() {
+			t.Error("open circuit reports no reopening time")
+		}
+		if !b.Allow("openai") {
+			t.Error("another provider was skipped")
+		}
+
+		b.RecordSuccess("claude")
+		if !b.Allow("claude") || !b.OpenUntil("claude").IsZero() {
+			t.Error("circuit still open after a success")
+		}
+	}
+}
+
+func TestCircuitBreakerReopensAfterCooldown(t *testing.T) {
+	b := NewCircuitBreaker("", 3, time.Minute, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		b.RecordFailure("claude")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if !b.Allow("claude") {
+		t.Fatal("circuit still open after the cooldown")
+	}
+
+	b.RecordFailure("claude")
+	if b.Allow("claude") {
+		t.Error("a failure right after the cooldown did not reopen the circuit")
+	}
+}
+
+func TestCircuitBreakerTrialEnds(t *testing.T) {
+	const window = 50 * time.Millisecond
+	b := NewCircuitBreaker(filepath.Join(t.TempDir(), "circuit.json"), 3, window, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		b.RecordFailure("claude")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if !b.Allow("claude") {
+		t.Fatal("circuit still open after the cooldown")
+	}
+
+	// A single failure long after the trial does not reopen the circuit
+	time.Sleep(2 * window)
+	b.RecordFailure("claude")
+	if !b.Allow("claude") {
+		t.Error("a failure long after the cooldown reopened the circuit below the threshold")
+	}
+
+	// Nor does one after a cooldown that expired without a trial
+	for i := 0; i < 3; i++ {
+		b.RecordFailure("claude")
+	}
+	time.Sleep(2 * window)
+	b.RecordFailure("claude")
+	if !b.Allow("claude") {
+		t.Error("a failure long after the cooldown reopened the circuit below the threshold")
+	}
+}
+
+func TestCircuitBreakerSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "circuit.json")
+
+	// Separate breakers stand for separate processes sharing the file
+	const processes = 20
+	var wg sync.WaitGroup
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NewCircuitBreaker(path, processes, time.Hour, time.Hour).RecordFailure("claude")
+		}()
+	}
+	wg.Wait()
+
+	var failures int
+	NewCircuitBreaker(path, 0, 0, 0).update(func(state map[string]*circuit) bool {
+		if c := state["claude"]; c != nil {
+			failures = len(c.Failures)
+		}
+		return false
+	})
+	if failures != processes {
+		t.Errorf("recorded %d failures, want %d", failures, processes)
+	}
+
+	if NewCircuitBreaker(path, processes, time.Hour, time.Hour).Allow("claude") {
+		t.Error("circuit closed after reaching the threshold from several processes")
+	}
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// ContextTrim describes context sections removed from a request so that it
+// fits the context window of a provider
+type ContextTrim struct {
+	Provider string
+	Sections []string
+	Tokens   int // size of the trimmed request
+	Limit    int
+}
+
+// SetContextBudget limits the input tokens of every request, in addition to
+// the context window of each provider. Zero leaves only the window.
+func (m *Manager) SetContextBudget(tokens int) {
+	m.contextBudget = tokens
+}
+
+// OnContextTrimmed registers a function called whenever context sections are
+// removed from a request to fit a provider's limit
+func (m *Manager) OnContextTrimmed(fn func(ContextTrim)) {
+	m.onContextTrimmed = fn
+}
+
+// inputLimit returns the input token limit of a request to the named
+// provider: its context window less the room reserved for the answer, capped
+// by the configured budget. Zero means unlimited.
+func (m *Manager) inputLimit(name string, provider providers.Provider, req *providers.Request) int {
+	caps := provider.GetCapabilities()
+
+	reserve := caps.MaxTokens
+	if configured := m.config(name).MaxTokens; configured > 0 {
+		reserve = configured
+	}
+
+	limit := 0
+	if caps.MaxContextSize > 0 {
+		limit = caps.MaxContextSize - req.MaxTokens(reserve)
+		if limit <= 0 {
+			limit = caps.MaxContextSize
+		}
+	}
+
+	if m.contextBudget > 0 && (limit == 0 || m.contextBudget < limit) {
+		limit = m.contextBudget
+	}
+
+	return limit
+}
+
+// fitContext returns req, or a copy with low-priority context removed, that
+// fits the input limit of provider
+func (m *Manager) fitContext(ctx context.Context, name string, provider providers.Provider, req *providers.Request) (*providers.Request, error) {
+	// Tool definitions only take room on providers that send them
+	if len(req.Tools) > 0 && !provider.GetCapabilities().FunctionCalling {
+		withoutTools := *req
+		withoutTools.Tools = nil
+		req = &withoutTools
+	}
+
+	limit := m.inputLimit(name, provider, req)
+	counter, _ := provider.(providers.TokenCounter)
+
+	fit, err := providers.FitContext(ctx, req, limit, counter)
+	if err != nil {
+		return nil, fmt.Errorf("%s (%s): %w", name, provider.GetInfo().Model, err)
+	}
+
+	if len(fit.Trimmed) > 0 && m.onContextTrimmed != nil {
+		m.onContextTrimmed(ContextTrim{Provider: name, Sections: fit.Trimmed, Tokens: fit.Tokens, Limit: limit})
+	}
+
+	return fit.Request, nil
+}
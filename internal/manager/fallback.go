@@ -0,0 +1,175 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// FallbackEvent describes a provider that was skipped or failed before
+// another provider in the fallback chain was tried
+type FallbackEvent struct {
+	Provider string
+	Err      error // nil when the provider was skipped by the circuit breaker
+}
+
+// SetFallback configures the providers tried, in order, after the requested
+// provider fails with a retryable or availability error. The breaker may be
+// nil to always try every provider.
+func (m *Manager) SetFallback(chain []string, breaker *CircuitBreaker) {
+	m.fallback = chain
+	m.breaker = breaker
+}
+
+// OnFallback registers a function called whenever a provider is skipped or
+// fails and the next provider in the chain is tried
+func (m *Manager) OnFallback(fn func(FallbackEvent)) {
+	m.onFallback = fn
+}
+
+// chain returns the providers to try for a request to name: name itself,
+// followed by the other fallback providers in configured order
+func (m *Manager) chain(name string) []string {
+	names := []string{name}
+	for _, fallback := range m.fallback {
+		if fallback != name {
+			names = append(names, fallback)
+		}
+	}
+	return names
+}
+
+// walkChain calls try for each provider in the chain of name until one
+// succeeds or fails with an error that another provider would not fix.
+// Providers with an open circuit are skipped, unless every provider is, and
+// so are providers lacking a capability the request needs or a context
+// window large enough for it. try receives the request trimmed to fit.
+func (m *Manager) walkChain(ctx context.Context, name string, req *providers.Request, try func(string, providers.ProviderV2, *providers.Request) error) error {
+	if _, err := m.GetProvider(name); err != nil {
+		return err
+	}
+
+	names := m.chain(name)
+
+	var candidates, skipped []string
+	for _, candidate := range names {
+		if m.breaker != nil && !m.breaker.Allow(candidate) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// Better to try a provider that has been failing than to give up
+	if len(candidates) == 0 {
+		candidates, skipped = names[:1], skipped[1:]
+	}
+
+	for _, candidate := range skipped {
+		m.notifyFallback(FallbackEvent{Provider: candidate})
+	}
+
+	var errs []error
+	for i, candidate := range candidates {
+		provider, err := m.GetProvider(candidate)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		fitted, err := m.prepare(ctx, candidate, provider, req)
+		if err != nil {
+			if len(candidates) == 1 {
+				return err
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", candidate, err))
+			if i < len(candidates)-1 {
+				m.notifyFallback(FallbackEvent{Provider: candidate, Err: err})
+			}
+			continue
+		}
+
+		err = try(candidate, m.wrap(candidate, providers.AsV2(provider)), fitted)
+		if err == nil {
+			if m.breaker != nil {
+				m.breaker.RecordSuccess(candidate)
+			}
+			return nil
+		}
+
+		if !isAvailabilityError(err) || ctx.Err() != nil {
+			return err
+		}
+
+		if m.breaker != nil {
+			m.breaker.RecordFailure(candidate)
+		}
+
+		if len(candidates) == 1 {
+			return err
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", candidate, err))
+		if i < len(candidates)-1 {
+			m.notifyFallback(FallbackEvent{Provider: candidate, Err: err})
+		}
+	}
+
+	return fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// prepare checks that provider can serve req and fits the request to its
+// context window
+func (m *Manager) prepare(ctx context.Context, name string, provider providers.Provider, req *providers.Request) (*providers.Request, error) {
+	if err := checkSupport(name, provider, req); err != nil {
+		return nil, err
+	}
+	return m.fitContext(ctx, name, provider, req)
+}
+
+// checkSupport rejects a request that needs a capability the provider lacks
+func checkSupport(name string, provider providers.Provider, req *providers.Request) error {
+	if !req.HasImages() {
+		return nil
+	}
+
+	caps := provider.GetCapabilities()
+	if !caps.ImageAnalysis {
+		return fmt.Errorf("%w: %s (%s)", providers.ErrImagesNotSupported, name, provider.GetInfo().Model)
+	}
+	if size := req.LargestImage(); caps.MaxImageSize > 0 && size > caps.MaxImageSize {
+		return fmt.Errorf("%w: %s (%s) accepts images up to %s, got one of %s", providers.ErrImageTooLarge,
+			name, provider.GetInfo().Model, providers.FormatImageSize(caps.MaxImageSize), providers.FormatImageSize(size))
+	}
+	return nil
+}
+
+// notifyFallback reports a fallback event to the registered function
+func (m *Manager) notifyFallback(event FallbackEvent) {
+	if m.onFallback != nil {
+		m.onFallback(event)
+	}
+}
+
+// isAvailabilityError reports whether err means the provider could not
+// answer right now, so that another provider should be tried
+func isAvailabilityError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if providers.IsRetryable(err) ||
+		errors.Is(err, providers.ErrServiceUnavailable) ||
+		errors.Is(err, providers.ErrRateLimitExceeded) ||
+		errors.Is(err, providers.ErrQuotaExceeded) {
+		return true
+	}
+
+	// Connection failures, such as a local server that is not running
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
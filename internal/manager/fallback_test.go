@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/providers/mock"
+)
+
+// capsProvider is a mock provider with the given capabilities
+type capsProvider struct {
+	providers.Provider
+	caps providers.Capabilities
+}
+
+func (p capsProvider) GetCapabilities() providers.Capabilities {
+	return p.caps
+}
+
+func newCapsProvider(t *testing.T, caps providers.Capabilities) providers.Provider {
+	t.Helper()
+
+	provider, err := mock.NewProvider(providers.Config{Type: "mock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return capsProvider{Provider: provider, caps: caps}
+}
+
+func TestCheckSupportImages(t *testing.T) {
+	withImage := func(size int) *providers.Request {
+		req := providers.NewRequest("what is this", nil)
+		req.Messages[0].Images = []providers.Image{{MediaType: "image/png", Data: make([]byte, size)}}
+		return req
+	}
+
+	tests := []struct {
+		name string
+		caps providers.Capabilities
+		req  *providers.Request
+		want error
+	}{
+		{"no images", providers.Capabilities{}, providers.NewRequest("hi", nil), nil},
+		{"no vision", providers.Capabilities{}, withImage(10), providers.ErrImagesNotSupported},
+		{"unknown limit", providers.Capabilities{ImageAnalysis: true}, withImage(10 << 20), nil},
+		{"within limit", providers.Capabilities{ImageAnalysis: true, MaxImageSize: 5 << 20}, withImage(5 << 20), nil},
+		{"over limit", providers.Capabilities{ImageAnalysis: true, MaxImageSize: 5 << 20}, withImage(5<<20 + 1), providers.ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSupport("test", newCapsProvider(t, tt.caps), tt.req)
+			if tt.want == nil && err != nil {
+				t.Fatalf("checkSupport = %v, want no error", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("checkSupport = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Health statuses of a provider
+const (
+	HealthOK               = "ok"
+	HealthInvalidConfig    = "invalid_config"
+	HealthAuthFailed       = "auth_failed"
+	HealthModelUnavailable = "model_unavailable"
+	HealthRateLimited      = "rate_limited"
+	HealthQuotaExceeded    = "quota_exceeded"
+	HealthTimeout          = "timeout"
+	HealthUnreachable      = "unreachable"
+)
+
+// Health is the result of checking a provider
+type Health struct {
+	Provider string
+	Info     providers.ProviderInfo
+
+	// Live is set when the provider was checked with a real call; the
+	// configuration of providers that cannot be pinged is only validated
+	Live    bool
+	Latency time.Duration
+	Err     error
+}
+
+// Status classifies the outcome of the check
+func (h Health) Status() string {
+	switch {
+	case h.Err == nil:
+		return HealthOK
+	case errors.Is(h.Err, context.DeadlineExceeded):
+		return HealthTimeout
+	case !h.Live:
+		return HealthInvalidConfig
+	case errors.Is(h.Err, providers.ErrInvalidAPIKey):
+		return HealthAuthFailed
+	case errors.Is(h.Err, providers.ErrInvalidModel):
+		return HealthModelUnavailable
+	case errors.Is(h.Err, providers.ErrRateLimitExceeded):
+		return HealthRateLimited
+	case errors.Is(h.Err, providers.ErrQuotaExceeded):
+		return HealthQuotaExceeded
+	default:
+		return HealthUnreachable
+	}
+}
+
+// HealthCheck checks every configured provider concurrently, pinging those
+// that support it, and gives each at most timeout. Results are sorted by
+// provider name.
+func (m *Manager) HealthCheck(ctx context.Context, timeout time.Duration) []Health {
+	names := m.Names()
+	results := make([]Health, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			results[i] = m.checkHealth(checkCtx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// checkHealth loads the named provider and pings it, measuring the latency
+// of the ping. Both must finish before ctx is done.
+func (m *Manager) checkHealth(ctx context.Context, name string) Health {
+	health := Health{Provider: name}
+
+	provider, err := m.provider(ctx, name)
+	if err != nil {
+		providerCfg := m.config(name)
+		health.Info = providers.ProviderInfo{Type: providerCfg.Type, Model: providerCfg.Model}
+		health.Err = m.loadError(name)
+		if health.Err == nil {
+			health.Err = err
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			health.Err = context.DeadlineExceeded
+		}
+		return health
+	}
+	health.Info = provider.GetInfo()
+
+	pinger, ok := provider.(providers.Pinger)
+	if !ok {
+		health.Err = provider.ValidateConfig()
+		return health
+	}
+
+	health.Live = true
+	startTime := time.Now()
+	health.Err = pinger.Ping(ctx)
+	health.Latency = time.Since(startTime)
+
+	// Report a timeout as such, whatever the provider wrapped it in
+	if health.Err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		health.Err = context.DeadlineExceeded
+	}
+
+	return health
+}
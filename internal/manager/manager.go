@@ -1,58 +1,327 @@
 package manager
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/Codilas/how/internal/config"
 	"github.com/Codilas/how/pkg/providers"
 )
 
-// Manager handles provider lifecycle and selection
+// Manager handles provider lifecycle and selection. Providers may be looked
+// up and used concurrently.
 type Manager struct {
-	providers map[string]providers.Provider
-	factory   *ProviderFactory
+	// mu guards the configured providers, which are created on first use,
+	// the errors of those that failed to load and the loads in progress.
+	// Providers are created without holding it, since validating some
+	// configurations takes a while.
+	mu         sync.RWMutex
+	providers  map[string]providers.Provider
+	configs    map[string]providers.Config
+	loadErrors map[string]error
+	loading    map[string]*loadCall
+	factory    *ProviderFactory
+
+	// Fallback chain, see SetFallback
+	fallback   []string
+	breaker    *CircuitBreaker
+	onFallback func(FallbackEvent)
+
+	// Input token limits, see SetContextBudget
+	contextBudget    int
+	onContextTrimmed func(ContextTrim)
+
+	// Routing rules, see SetRouting
+	routingRules   []routingRule
+	preferredTypes []string
+
+	middleware []Middleware
 }
 
+// Middleware wraps the provider serving a request, to add behavior such as
+// caching around Send and Stream. name is the configured provider name.
+type Middleware func(name string, next providers.ProviderV2) providers.ProviderV2
+
 // NewManager creates a new provider manager
 func NewManager() *Manager {
 	return &Manager{
-		providers: make(map[string]providers.Provider),
-		factory:   defaultFactory,
+		providers:  make(map[string]providers.Provider),
+		configs:    make(map[string]providers.Config),
+		loadErrors: make(map[string]error),
+		loading:    make(map[string]*loadCall),
+		factory:    defaultFactory,
 	}
 }
 
-// LoadProviders initializes all configured providers
-func (m *Manager) LoadProviders(cfg map[string]config.ProviderConfig) error {
+// LoadProviders registers the configured providers. Each one is created and
+// validated when it is first used, so a misconfigured provider only fails
+// the requests made to it; see Statuses for the errors.
+func (m *Manager) LoadProviders(cfg map[string]config.ProviderConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	for name, providerCfg := range cfg {
-		provider, err := m.factory.CreateProvider(convertCfg(providerCfg))
+		m.configs[name] = convertCfg(providerCfg)
+		delete(m.providers, name)
+		delete(m.loadErrors, name)
+		delete(m.loading, name)
+	}
+}
+
+// GetProvider retrieves a provider by name, creating it on first use
+func (m *Manager) GetProvider(name string) (providers.Provider, error) {
+	return m.provider(context.Background(), name)
+}
+
+// provider retrieves a provider by name, creating it on first use, and
+// gives up waiting for it to be created when ctx is done
+func (m *Manager) provider(ctx context.Context, name string) (providers.Provider, error) {
+	m.mu.RLock()
+	provider, loaded := m.providers[name]
+	m.mu.RUnlock()
+
+	if loaded {
+		return provider, nil
+	}
+	return m.load(ctx, name)
+}
+
+// loadCall is the creation of a provider, shared by everyone waiting for it
+type loadCall struct {
+	done     chan struct{}
+	provider providers.Provider
+	err      error
+}
+
+// load creates the named provider from its configuration, or waits for the
+// load already in progress. A provider that fails to load is not retried;
+// its error is returned by later calls.
+func (m *Manager) load(ctx context.Context, name string) (providers.Provider, error) {
+	m.mu.Lock()
+	if provider, ok := m.providers[name]; ok {
+		m.mu.Unlock()
+		return provider, nil
+	}
+	if err, failed := m.loadErrors[name]; failed {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("failed to load provider %s: %w", name, err)
+	}
+
+	providerCfg, ok := m.configs[name]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("provider %s not found", name)
+	}
+
+	call, inProgress := m.loading[name]
+	if !inProgress {
+		call = &loadCall{done: make(chan struct{})}
+		m.loading[name] = call
+		go m.create(name, providerCfg, call)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to load provider %s: %w", name, ctx.Err())
+	}
+
+	if call.err != nil {
+		return nil, fmt.Errorf("failed to load provider %s: %w", name, call.err)
+	}
+	return call.provider, nil
+}
+
+// create creates a provider for a load call and records the outcome, unless
+// the provider was reconfigured in the meantime
+func (m *Manager) create(name string, providerCfg providers.Config, call *loadCall) {
+	call.provider, call.err = m.factory.CreateProvider(providerCfg)
+
+	m.mu.Lock()
+	if m.loading[name] == call {
+		delete(m.loading, name)
+		if call.err != nil {
+			m.loadErrors[name] = call.err
+		} else {
+			m.providers[name] = call.provider
+		}
+	}
+	m.mu.Unlock()
+
+	close(call.done)
+}
+
+// Names returns the names of the configured providers, sorted
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.configs))
+	for name := range m.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// config returns the configuration of the named provider
+func (m *Manager) config(name string) providers.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.configs[name]
+}
+
+// loadError returns the error the named provider failed to load with
+func (m *Manager) loadError(name string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.loadErrors[name]
+}
+
+// ProviderStatus is a configured provider and the error that kept it from
+// loading, if any. Info is taken from the configuration when it failed.
+type ProviderStatus struct {
+	Name string
+	Info providers.ProviderInfo
+	Err  error
+}
+
+// Statuses loads every configured provider and reports whether it loaded,
+// sorted by name
+func (m *Manager) Statuses() []ProviderStatus {
+	var statuses []ProviderStatus
+
+	for _, name := range m.Names() {
+		status := ProviderStatus{Name: name}
+
+		provider, err := m.GetProvider(name)
 		if err != nil {
-			return fmt.Errorf("failed to load provider %s: %w", name, err)
+			providerCfg := m.config(name)
+			status.Info = providers.ProviderInfo{Type: providerCfg.Type, Model: providerCfg.Model}
+			status.Err = m.loadError(name)
+		} else {
+			status.Info = provider.GetInfo()
 		}
 
-		m.providers[name] = provider
+		statuses = append(statuses, status)
 	}
 
-	return nil
+	return statuses
 }
 
-// GetProvider retrieves a provider by name
-func (m *Manager) GetProvider(name string) (providers.Provider, error) {
-	provider, exists := m.providers[name]
-	if !exists {
-		return nil, fmt.Errorf("provider %s not found", name)
+// Use adds a middleware around the providers used by Send and Stream. The
+// middleware added first is the outermost.
+func (m *Manager) Use(mw Middleware) {
+	m.middleware = append(m.middleware, mw)
+}
+
+// wrap applies the middleware to a provider
+func (m *Manager) wrap(name string, provider providers.ProviderV2) providers.ProviderV2 {
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		provider = m.middleware[i](name, provider)
 	}
+	return provider
+}
+
+// Send sends a request to the named provider and waits for the response,
+// falling back to the next provider in the chain when it is unavailable.
+// The response reports the name of the provider that answered.
+// Providers that only implement the legacy interface are adapted.
+func (m *Manager) Send(ctx context.Context, name string, req *providers.Request) (*providers.Response, error) {
+	var response *providers.Response
+	err := m.walkChain(ctx, name, req, func(candidate string, provider providers.ProviderV2, req *providers.Request) error {
+		resp, err := provider.Send(ctx, req)
+		if err != nil {
+			return err
+		}
 
-	return provider, nil
+		resp.Provider = candidate
+		response = resp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
-// ListProviders returns information about all loaded providers
+// Stream sends a request to the named provider and returns a streaming
+// response. It falls back to the next provider in the chain when the
+// provider is unavailable, including errors reported before the first chunk
+// of text, and names the provider that answered in the final chunk's
+// metadata.
+func (m *Manager) Stream(ctx context.Context, name string, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	var stream <-chan providers.StreamResponse
+	err := m.walkChain(ctx, name, req, func(candidate string, provider providers.ProviderV2, req *providers.Request) error {
+		chunks, err := provider.Stream(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		first, ok := <-chunks
+		if !ok {
+			// Closed without a chunk: the request was cancelled
+			empty := make(chan providers.StreamResponse)
+			close(empty)
+			stream = empty
+			return ctx.Err()
+		}
+		if first.Error != nil {
+			return first.Error
+		}
+
+		stream = relayStream(ctx, candidate, first, chunks)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// relayStream forwards the chunks of a stream whose first chunk was already
+// read, adding the provider name to the final chunk's metadata
+func relayStream(ctx context.Context, name string, first providers.StreamResponse, in <-chan providers.StreamResponse) <-chan providers.StreamResponse {
+	out := make(chan providers.StreamResponse)
+
+	go func() {
+		defer close(out)
+
+		chunk, ok := first, true
+		for ok {
+			if chunk.Done {
+				metadata := make(map[string]interface{}, len(chunk.Metadata)+1)
+				for k, v := range chunk.Metadata {
+					metadata[k] = v
+				}
+				metadata[providers.MetadataProvider] = name
+				chunk.Metadata = metadata
+			}
+
+			if !providers.SendChunk(ctx, out, chunk) {
+				return
+			}
+			chunk, ok = <-in
+		}
+	}()
+
+	return out
+}
+
+// ListProviders returns information about all providers that load
 func (m *Manager) ListProviders() []providers.ProviderInfo {
 	var infos []providers.ProviderInfo
 
-	for _, provider := range m.providers {
-		infos = append(infos, provider.GetInfo())
+	for _, status := range m.Statuses() {
+		if status.Err == nil {
+			infos = append(infos, status.Info)
+		}
 	}
 
 	// Sort by name for consistent output
@@ -67,8 +336,12 @@ func (m *Manager) ListProviders() []providers.ProviderInfo {
 func (m *Manager) ValidateProviders() map[string]error {
 	errors := make(map[string]error)
 
-	for name, provider := range m.providers {
-		if err := provider.ValidateConfig(); err != nil {
+	for _, name := range m.Names() {
+		provider, err := m.GetProvider(name)
+		if err == nil {
+			err = provider.ValidateConfig()
+		}
+		if err != nil {
 			errors[name] = err
 		}
 	}
@@ -86,98 +359,159 @@ func (m *Manager) GetProviderCapabilities(name string) (providers.Capabilities,
 	return provider.GetCapabilities(), nil
 }
 
-// SelectBestProvider chooses the best provider for a given task
+// SelectBestProvider chooses the best provider for a given task. Providers
+// missing a required capability are never chosen. Providers are rated from
+// their configuration and the model registry, so that only the one chosen
+// is loaded; when it fails to load, the next best is tried.
 func (m *Manager) SelectBestProvider(requirements ProviderRequirements) (string, providers.Provider, error) {
-	var bestProvider string
-	var bestScore int
+	type candidate struct {
+		name  string
+		score int
+	}
 
-	for name, provider := range m.providers {
-		score := m.scoreProvider(provider, requirements)
-		if score > bestScore {
-			bestScore = score
-			bestProvider = name
+	var candidates []candidate
+	for _, name := range m.Names() {
+		caps, ok := m.capabilities(name)
+		if !ok {
+			continue
+		}
+
+		if score := scoreProvider(m.config(name).Type, caps, requirements); score > 0 {
+			candidates = append(candidates, candidate{name: name, score: score})
 		}
 	}
 
-	if bestProvider == "" {
-		return "", nil, fmt.Errorf("no suitable provider found for requirements")
+	// Names are sorted, so equal scores keep that order
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].name == requirements.PreferredProvider
+	})
+
+	for _, c := range candidates {
+		if provider, err := m.GetProvider(c.name); err == nil {
+			return c.name, provider, nil
+		}
 	}
 
-	return bestProvider, m.providers[bestProvider], nil
+	return "", nil, fmt.Errorf("no suitable provider found for requirements")
 }
 
-// ProviderRequirements defines what features are needed
+// ProviderRequirements defines what features are needed. Image analysis and
+// the context size are required; the other features are preferred.
 type ProviderRequirements struct {
 	Streaming       bool
 	FunctionCalling bool
 	ImageAnalysis   bool
+	ImageSize       int // largest image, in bytes
 	MinContextSize  int
 	PreferredTypes  []string // e.g., ["anthropic", "openai"]
+
+	// PreferredProvider is chosen among equally suitable providers
+	PreferredProvider string
 }
 
-// scoreProvider rates how well a provider matches requirements
-func (m *Manager) scoreProvider(provider providers.Provider, req ProviderRequirements) int {
-	caps := provider.GetCapabilities()
-	info := provider.GetInfo()
-	score := 0
+// capabilities returns the capabilities of the named provider without
+// loading it: those it reports once loaded, otherwise those of its model
+// in the registry with the configured overrides. It reports false for
+// providers that failed to load.
+func (m *Manager) capabilities(name string) (providers.Capabilities, bool) {
+	m.mu.RLock()
+	provider, loaded := m.providers[name]
+	_, failed := m.loadErrors[name]
+	providerCfg := m.configs[name]
+	m.mu.RUnlock()
+
+	switch {
+	case loaded:
+		return provider.GetCapabilities(), true
+	case failed:
+		return providers.Capabilities{}, false
+	}
 
-	// Check required capabilities
+	// Only the model tells whether images and tools are supported
+	caps := providers.Capabilities{Streaming: true}
+	if model, ok := providers.LookupModel(providerCfg.Model); ok {
+		caps.ImageAnalysis = model.Vision
+		caps.FunctionCalling = model.Tools
+		caps.MaxContextSize = model.ContextWindow
+		caps.MaxTokens = model.MaxOutputTokens
+	}
+
+	return providerCfg.Capabilities.Apply(caps), true
+}
+
+// scoreProvider rates how well a provider of the given type and capabilities
+// matches requirements. Providers that cannot serve the request get zero.
+func scoreProvider(providerType string, caps providers.Capabilities, req ProviderRequirements) int {
+	if len(missingCapabilities(caps, req)) > 0 {
+		return 0
+	}
+
+	score := 1
+
+	// Check preferred capabilities
 	if req.Streaming && caps.Streaming {
 		score += 10
 	}
 	if req.FunctionCalling && caps.FunctionCalling {
 		score += 10
 	}
-	if req.ImageAnalysis && caps.ImageAnalysis {
-		score += 10
-	}
-	if caps.MaxContextSize >= req.MinContextSize {
-		score += 5
-	}
 
 	// Prefer certain provider types
 	for _, preferred := range req.PreferredTypes {
-		if info.Type == preferred {
+		if providerType == preferred {
 			score += 20
 			break
 		}
 	}
 
-	// Validate configuration
-	if provider.ValidateConfig() != nil {
-		score = 0 // Invalid providers get zero score
-	}
-
 	return score
 }
 
-// HealthCheck tests all providers
-func (m *Manager) HealthCheck() map[string]error {
-	results := make(map[string]error)
-
-	for name, provider := range m.providers {
-		// Simple validation check
-		err := provider.ValidateConfig()
-		results[name] = err
+// missingCapabilities lists the required capabilities missing from caps
+func missingCapabilities(caps providers.Capabilities, req ProviderRequirements) []string {
+	var missing []string
+	if req.ImageAnalysis && !caps.ImageAnalysis {
+		missing = append(missing, "image analysis")
+	}
+	if caps.MaxImageSize > 0 && caps.MaxImageSize < req.ImageSize {
+		missing = append(missing, fmt.Sprintf("images of %s (takes up to %s)", providers.FormatImageSize(req.ImageSize), providers.FormatImageSize(caps.MaxImageSize)))
+	}
+	// An unknown context size is not a limit
+	if caps.MaxContextSize > 0 && caps.MaxContextSize < req.MinContextSize {
+		missing = append(missing, fmt.Sprintf("a context window of %d tokens (has %d)", req.MinContextSize, caps.MaxContextSize))
 	}
 
-	return results
+	return missing
 }
 
 // ReloadProvider reloads a specific provider with new configuration
 func (m *Manager) ReloadProvider(name string, cfg config.ProviderConfig) error {
-	provider, err := m.factory.CreateProvider(convertCfg(cfg))
+	converted := convertCfg(cfg)
+	provider, err := m.factory.CreateProvider(converted)
 	if err != nil {
 		return fmt.Errorf("failed to reload provider %s: %w", name, err)
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.providers[name] = provider
+	m.configs[name] = converted
+	delete(m.loadErrors, name)
 	return nil
 }
 
 // RemoveProvider removes a provider from the manager
 func (m *Manager) RemoveProvider(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	delete(m.providers, name)
+	delete(m.configs, name)
+	delete(m.loadErrors, name)
 }
 
 // GetAvailableTypes returns all available provider types
@@ -185,13 +519,86 @@ func (m *Manager) GetAvailableTypes() []string {
 	return m.factory.GetSupportedProviders()
 }
 
+// RegisterModels adds the configured models to the model registry, merging
+// each with the registry entry it overrides
+func RegisterModels(models map[string]config.ModelConfig) {
+	for id, modelCfg := range models {
+		info, ok := providers.LookupModel(id)
+		if !ok {
+			// Leave the features of unknown models to their provider
+			info = providers.ModelInfo{Vision: true, Tools: true}
+		}
+		info.ID = id
+
+		if modelCfg.ContextWindow > 0 {
+			info.ContextWindow = modelCfg.ContextWindow
+		}
+		if modelCfg.MaxOutputTokens > 0 {
+			info.MaxOutputTokens = modelCfg.MaxOutputTokens
+		}
+		if modelCfg.Vision != nil {
+			info.Vision = *modelCfg.Vision
+		}
+		if modelCfg.Tools != nil {
+			info.Tools = *modelCfg.Tools
+		}
+		if modelCfg.Pricing != nil {
+			info.InputPrice = modelCfg.Pricing.Input
+			info.OutputPrice = modelCfg.Pricing.Output
+		}
+
+		providers.RegisterModel(info)
+	}
+}
+
 // convertCfg converts a config.ProviderConfig to providers.Config
 func convertCfg(cfg config.ProviderConfig) providers.Config {
-	return providers.Config{
-		Type:      cfg.Type,
-		APIKey:    cfg.APIKey,
-		Model:     cfg.Model,
-		BaseURL:   cfg.BaseURL,
-		MaxTokens: cfg.MaxTokens,
+	providerCfg := providers.Config{
+		Type:          cfg.Type,
+		APIKey:        cfg.APIKey,
+		Model:         cfg.Model,
+		BaseURL:       cfg.BaseURL,
+		MaxTokens:     cfg.MaxTokens,
+		Temperature:   cfg.Temperature,
+		TopP:          cfg.TopP,
+		SystemPrompt:  cfg.SystemPrompt,
+		CustomHeaders: cfg.CustomHeaders,
+		Fixtures:      cfg.Fixtures,
+		Command:       cfg.Command,
+		Args:          cfg.Args,
+		Timeout:       cfg.Timeout,
+
+		ThinkingBudget:       cfg.ThinkingBudget,
+		DisablePromptCaching: cfg.DisablePromptCaching,
+	}
+
+	if cfg.Capabilities != nil {
+		providerCfg.Capabilities = &providers.CapabilityOverrides{
+			Streaming:      cfg.Capabilities.Streaming,
+			ImageAnalysis:  cfg.Capabilities.ImageAnalysis,
+			MaxContextSize: cfg.Capabilities.MaxContextSize,
+			MaxTokens:      cfg.Capabilities.MaxTokens,
+		}
 	}
+
+	if cfg.Network != nil {
+		providerCfg.Network = &providers.NetworkConfig{
+			ConnectTimeout: cfg.Network.ConnectTimeout,
+			Proxy:          cfg.Network.Proxy,
+			CAFile:         cfg.Network.CAFile,
+			ClientCert:     cfg.Network.ClientCert,
+			ClientKey:      cfg.Network.ClientKey,
+			TLSMinVersion:  cfg.Network.TLSMinVersion,
+		}
+	}
+
+	if cfg.Retry != nil {
+		providerCfg.Retry = &providers.RetryPolicy{
+			MaxRetries:     cfg.Retry.MaxRetries,
+			InitialBackoff: cfg.Retry.InitialBackoff,
+			MaxBackoff:     cfg.Retry.MaxBackoff,
+		}
+	}
+
+	return providerCfg
 }
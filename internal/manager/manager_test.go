@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Codilas/how/internal/config"
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/providers/mock"
+)
+
+// newTestManager creates a manager whose "slow" providers are only created
+// once release is closed, counting the providers created
+func newTestManager(t *testing.T, created *int32, release chan struct{}) *Manager {
+	t.Helper()
+
+	m := NewManager()
+	m.factory = NewProviderFactory()
+	m.factory.RegisterProvider("mock", func(cfg providers.Config) (providers.Provider, error) {
+		atomic.AddInt32(created, 1)
+		return mock.NewProvider(cfg)
+	})
+	m.factory.RegisterProvider("slow", func(cfg providers.Config) (providers.Provider, error) {
+		atomic.AddInt32(created, 1)
+		<-release
+		return mock.NewProvider(cfg)
+	})
+	m.factory.RegisterProvider("broken", func(cfg providers.Config) (providers.Provider, error) {
+		atomic.AddInt32(created, 1)
+		return nil, errors.New("broken")
+	})
+
+	m.LoadProviders(map[string]config.ProviderConfig{
+		"fast":   {Type: "mock"},
+		"slow":   {Type: "slow"},
+		"broken": {Type: "broken"},
+	})
+	return m
+}
+
+func TestSlowLoadDoesNotBlockOtherProviders(t *testing.T) {
+	var created int32
+	release := make(chan struct{})
+	defer close(release)
+	m := newTestManager(t, &created, release)
+
+	if _, err := m.GetProvider("fast"); err != nil {
+		t.Fatal(err)
+	}
+
+	go m.GetProvider("slow")
+
+	done := make(chan error)
+	go func() {
+		_, err := m.GetProvider("fast")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a loaded provider waited for another provider to load")
+	}
+}
+
+func TestConcurrentLoadsCreateOnce(t *testing.T) {
+	var created int32
+	release := make(chan struct{})
+	m := newTestManager(t, &created, release)
+
+	var wg sync.WaitGroup
+	loaded := make([]providers.Provider, 10)
+	for i := range loaded {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			loaded[i], _ = m.GetProvider("slow")
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("created %d providers, want 1", created)
+	}
+	for i, provider := range loaded {
+		if provider == nil || provider != loaded[0] {
+			t.Fatalf("load %d returned %v, want the shared provider", i, provider)
+		}
+	}
+}
+
+func TestLoadHonoursContext(t *testing.T) {
+	var created int32
+	release := make(chan struct{})
+	defer close(release)
+	m := newTestManager(t, &created, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := m.provider(ctx, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("provider = %v, want the deadline to be exceeded", err)
+	}
+
+	health := m.checkHealth(ctx, "slow")
+	if health.Status() != HealthTimeout {
+		t.Errorf("health status = %s, want %s", health.Status(), HealthTimeout)
+	}
+}
+
+func TestLoadErrorIsKept(t *testing.T) {
+	var created int32
+	m := newTestManager(t, &created, nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.GetProvider("broken"); err == nil {
+			t.Fatal("broken provider loaded")
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d providers, want 1", created)
+	}
+
+	health := m.checkHealth(context.Background(), "broken")
+	if health.Status() != HealthInvalidConfig {
+		t.Errorf("health status = %s, want %s", health.Status(), HealthInvalidConfig)
+	}
+}
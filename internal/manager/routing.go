@@ -0,0 +1,182 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Codilas/how/internal/config"
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Route is the provider chosen for a request and why
+type Route struct {
+	Provider string
+
+	// Rule is the name of the rule that chose the provider, empty when it
+	// was chosen by its capabilities
+	Rule string
+
+	// Reasons explain the choice, and the rules passed over, in order
+	Reasons []string
+}
+
+// routingRule is a configured rule with its pattern compiled
+type routingRule struct {
+	config.RoutingRule
+	match *regexp.Regexp
+}
+
+// SetRouting sets the rules and preferred provider types used by Route
+func (m *Manager) SetRouting(rules []config.RoutingRule, preferredTypes []string) error {
+	compiled := make([]routingRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Provider == "" {
+			return fmt.Errorf("routing rule %s has no provider", ruleName(rule, i))
+		}
+
+		compiledRule := routingRule{RoutingRule: rule}
+		if rule.Match != "" {
+			match, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return fmt.Errorf("invalid pattern in routing rule %s: %w", ruleName(rule, i), err)
+			}
+			compiledRule.match = match
+		}
+		compiled = append(compiled, compiledRule)
+	}
+
+	m.routingRules = compiled
+	m.preferredTypes = preferredTypes
+	return nil
+}
+
+// Route picks the provider for req: that of the first rule matching it,
+// when the provider can serve it, otherwise the provider best meeting its
+// requirements. current is kept among equally suitable providers, and when
+// no provider meets the requirements.
+func (m *Manager) Route(req *providers.Request, current string, streaming bool) Route {
+	promptTokens := providers.EstimateTokens(req.Prompt())
+	requestTokens, err := providers.EstimateRequestTokens(req)
+	if err != nil {
+		requestTokens = promptTokens
+	}
+
+	requirements := ProviderRequirements{
+		Streaming:         streaming,
+		FunctionCalling:   len(req.Tools) > 0,
+		ImageAnalysis:     req.HasImages(),
+		ImageSize:         req.LargestImage(),
+		MinContextSize:    requestTokens,
+		PreferredTypes:    m.preferredTypes,
+		PreferredProvider: current,
+	}
+
+	var route Route
+	for i, rule := range m.routingRules {
+		conditions, ok := rule.matches(req, promptTokens, requestTokens)
+		if !ok {
+			continue
+		}
+
+		name := ruleName(rule.RoutingRule, i)
+		provider, err := m.GetProvider(rule.Provider)
+		if err != nil {
+			route.Reasons = append(route.Reasons, fmt.Sprintf("rule %s matched, but %v", name, err))
+			continue
+		}
+		if missing := missingCapabilities(provider.GetCapabilities(), requirements); len(missing) > 0 {
+			route.Reasons = append(route.Reasons, fmt.Sprintf("rule %s matched, but %s lacks %s", name, rule.Provider, strings.Join(missing, " and ")))
+			continue
+		}
+
+		route.Provider, route.Rule = rule.Provider, name
+		route.Reasons = append(route.Reasons, fmt.Sprintf("rule %s matched: %s", name, conditions))
+		return route
+	}
+
+	needs := requirements.describe()
+	best, _, err := m.SelectBestProvider(requirements)
+	if err != nil {
+		route.Provider = current
+		route.Reasons = append(route.Reasons, fmt.Sprintf("no provider meets the requirements (%s), keeping %s", needs, current))
+		return route
+	}
+
+	route.Provider = best
+	route.Reasons = append(route.Reasons, "best match for "+needs)
+	return route
+}
+
+// matches reports whether req meets all conditions of the rule, and
+// describes them
+func (r routingRule) matches(req *providers.Request, promptTokens, requestTokens int) (string, bool) {
+	var conditions []string
+
+	if r.match != nil {
+		if !r.match.MatchString(req.Prompt()) {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("prompt matches %q", r.Match))
+	}
+
+	if r.MaxPromptTokens > 0 {
+		if promptTokens > r.MaxPromptTokens {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("prompt of ~%d tokens (at most %d)", promptTokens, r.MaxPromptTokens))
+	}
+
+	if r.MinContextTokens > 0 {
+		if requestTokens < r.MinContextTokens {
+			return "", false
+		}
+		conditions = append(conditions, fmt.Sprintf("request of ~%d tokens (at least %d)", requestTokens, r.MinContextTokens))
+	}
+
+	if r.Images != nil {
+		if req.HasImages() != *r.Images {
+			return "", false
+		}
+		conditions = append(conditions, describeCondition(*r.Images, "images attached"))
+	}
+
+	if len(conditions) == 0 {
+		return "no conditions", true
+	}
+	return strings.Join(conditions, ", "), true
+}
+
+// describe lists the requirements for explaining a route
+func (r ProviderRequirements) describe() string {
+	var needs []string
+	if r.ImageAnalysis {
+		needs = append(needs, "image analysis")
+	}
+	if r.FunctionCalling {
+		needs = append(needs, "tools")
+	}
+	if r.Streaming {
+		needs = append(needs, "streaming")
+	}
+	needs = append(needs, fmt.Sprintf("~%d tokens of context", r.MinContextSize))
+	if len(r.PreferredTypes) > 0 {
+		needs = append(needs, "preferring "+strings.Join(r.PreferredTypes, ", "))
+	}
+	return strings.Join(needs, ", ")
+}
+
+func describeCondition(want bool, condition string) string {
+	if want {
+		return condition
+	}
+	return "no " + condition
+}
+
+// ruleName names a rule in messages, by its position when it has no name
+func ruleName(rule config.RoutingRule, i int) string {
+	if rule.Name != "" {
+		return fmt.Sprintf("%q", rule.Name)
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
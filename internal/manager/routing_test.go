@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Codilas/how/internal/config"
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/providers/mock"
+)
+
+// newRoutingManager creates a manager of mock providers, counting the
+// providers loaded
+func newRoutingManager(t *testing.T, loaded *int32, cfg map[string]config.ProviderConfig) *Manager {
+	t.Helper()
+
+	m := NewManager()
+	m.factory = NewProviderFactory()
+	m.factory.RegisterProvider("mock", func(cfg providers.Config) (providers.Provider, error) {
+		atomic.AddInt32(loaded, 1)
+		return mock.NewProvider(cfg)
+	})
+	m.LoadProviders(cfg)
+	return m
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func imageRequest(prompt string) *providers.Request {
+	req := providers.NewRequest(prompt, nil)
+	req.Messages[len(req.Messages)-1].Images = []providers.Image{{MediaType: "image/png", Data: []byte("png")}}
+	return req
+}
+
+func TestRouteRules(t *testing.T) {
+	var loaded int32
+	m := newRoutingManager(t, &loaded, map[string]config.ProviderConfig{
+		"small":  {Type: "mock", Model: "claude-3-5-haiku"},
+		"vision": {Type: "mock", Model: "gpt-4o", Capabilities: &config.CapabilitiesConfig{ImageAnalysis: boolPtr(true)}},
+		"big":    {Type: "mock", Model: "gemini-2.5-pro"},
+	})
+
+	err := m.SetRouting([]config.RoutingRule{
+		{Name: "quick", MaxPromptTokens: 10, Images: boolPtr(false), Provider: "small"},
+		{Name: "k8s", Match: "(?i)kubectl", Provider: "small"},
+		{Name: "images", Images: boolPtr(true), Provider: "small"},
+		{Name: "large", MinContextTokens: 1000, Provider: "big"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *providers.Request
+		provider string
+		rule     string
+	}{
+		{"short prompt", providers.NewRequest("what is ls", nil), "small", `"quick"`},
+		{"pattern", providers.NewRequest("explain how kubectl rollout works for a deployment in detail please", nil), "small", `"k8s"`},
+		{"large request", providers.NewRequest(strings.Repeat("word ", 2000), nil), "big", `"large"`},
+		// The images rule names a provider without vision, so the best
+		// provider that has it is picked instead
+		{"images", imageRequest("what is in this picture"), "vision", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := m.Route(tt.req, "big", false)
+			if route.Provider != tt.provider || route.Rule != tt.rule {
+				t.Errorf("Route = %s by rule %q, want %s by rule %q (%s)",
+					route.Provider, route.Rule, tt.provider, tt.rule, strings.Join(route.Reasons, "; "))
+			}
+		})
+	}
+}
+
+func TestRouteLoadsOnlyTheWinner(t *testing.T) {
+	var loaded int32
+	m := newRoutingManager(t, &loaded, map[string]config.ProviderConfig{
+		"a": {Type: "mock", Model: "gpt-4o-mini"},
+		"b": {Type: "mock", Model: "gpt-4o-mini"},
+		"c": {Type: "mock", Model: "gpt-4o-mini"},
+		"d": {Type: "mock", Model: "gpt-4o-mini"},
+	})
+	if err := m.SetRouting(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	route := m.Route(providers.NewRequest("hello", nil), "c", true)
+	if route.Provider != "c" {
+		t.Errorf("Route = %s, want the current provider among equals", route.Provider)
+	}
+	if loaded != 1 {
+		t.Errorf("loaded %d providers, want only the one chosen", loaded)
+	}
+}
+
+func TestRouteKeepsCurrentWhenNothingFits(t *testing.T) {
+	var loaded int32
+	m := newRoutingManager(t, &loaded, map[string]config.ProviderConfig{
+		"text": {Type: "mock", Model: "gpt-3.5-turbo"},
+	})
+	if err := m.SetRouting(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	route := m.Route(imageRequest("describe this"), "text", false)
+	if route.Provider != "text" || route.Rule != "" {
+		t.Errorf("Route = %s by rule %q, want to keep text", route.Provider, route.Rule)
+	}
+	if len(route.Reasons) == 0 || !strings.Contains(route.Reasons[len(route.Reasons)-1], "no provider meets") {
+		t.Errorf("reasons %q do not explain that nothing fits", route.Reasons)
+	}
+}
+
+func TestSelectBestProviderPrefersTypesAndSkipsFailedLoads(t *testing.T) {
+	var loaded int32
+	m := newRoutingManager(t, &loaded, map[string]config.ProviderConfig{
+		"broken": {Type: "missing", Model: "gpt-4o"},
+		"plain":  {Type: "mock", Model: "gpt-4o"},
+	})
+
+	name, _, err := m.SelectBestProvider(ProviderRequirements{PreferredTypes: []string{"missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "plain" {
+		t.Errorf("SelectBestProvider = %s, want the provider that loads", name)
+	}
+}
+
+func TestSetRoutingRejectsInvalidRules(t *testing.T) {
+	m := NewManager()
+
+	if err := m.SetRouting([]config.RoutingRule{{Match: "x"}}, nil); err == nil {
+		t.Error("rule without a provider accepted")
+	}
+	if err := m.SetRouting([]config.RoutingRule{{Match: "(", Provider: "a"}}, nil); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
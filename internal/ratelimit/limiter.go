@@ -0,0 +1,298 @@
+// Package ratelimit keeps requests to each provider within client-side
+// limits on requests and tokens per minute and on concurrent requests. The
+// limits are token buckets whose state is kept in a locked file, so that
+// they are shared by every invocation of the command running at once.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Codilas/how/internal/filelock"
+)
+
+const (
+	// pollInterval is how often a request waiting for a concurrency slot
+	// checks for a free one
+	pollInterval = 200 * time.Millisecond
+
+	// staleSlot is how long a slot is kept for a process that appears
+	// alive, in case its process ID was reused
+	staleSlot = time.Hour
+)
+
+// Reasons for waiting
+const (
+	ReasonRequests   = "requests per minute"
+	ReasonTokens     = "tokens per minute"
+	ReasonConcurrent = "concurrent requests"
+)
+
+// Limits are the limits of a provider. Zero means unlimited.
+type Limits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+	MaxConcurrent     int
+}
+
+// Unlimited reports whether no limit is set
+func (l Limits) Unlimited() bool {
+	return l.RequestsPerMinute <= 0 && l.TokensPerMinute <= 0 && l.MaxConcurrent <= 0
+}
+
+// Limiter hands out permits to send requests, sharing its state with other
+// processes through a file
+type Limiter struct {
+	path string
+}
+
+// NewLimiter creates a limiter whose state is kept at path
+func NewLimiter(path string) *Limiter {
+	return &Limiter{path: path}
+}
+
+// bucket is the shared state of the limits of one provider
+type bucket struct {
+	// Requests and Tokens available, as of Updated. Tokens may be negative
+	// when requests used more tokens than reserved.
+	Requests float64   `json:"requests"`
+	Tokens   float64   `json:"tokens"`
+	Updated  time.Time `json:"updated"`
+
+	// Slots are the requests in flight
+	Slots []slot `json:"slots,omitempty"`
+}
+
+// slot is a request in flight, held by a process
+type slot struct {
+	PID   int       `json:"pid"`
+	ID    int64     `json:"id"`
+	Since time.Time `json:"since"`
+}
+
+// nextSlotID numbers the slots taken by this process
+var nextSlotID int64
+
+// Permit allows a request to be sent. It must be released once the request
+// is done.
+type Permit struct {
+	limiter *Limiter
+	name    string
+	limits  Limits
+	slot    slot
+
+	// reserved is the number of tokens taken from the bucket
+	reserved int
+
+	// Waited is how long the request was queued, and Reason the last limit
+	// it waited for
+	Waited time.Duration
+	Reason string
+}
+
+// Acquire waits until the named provider's limits allow a request of about
+// tokens input tokens, and takes a permit for it
+func (l *Limiter) Acquire(ctx context.Context, name string, limits Limits, tokens int) (*Permit, error) {
+	// A single request larger than the bucket would wait forever
+	switch {
+	case limits.TokensPerMinute <= 0:
+		tokens = 0
+	case tokens > limits.TokensPerMinute:
+		tokens = limits.TokensPerMinute
+	}
+
+	permit := &Permit{
+		limiter:  l,
+		name:     name,
+		limits:   limits,
+		slot:     slot{PID: os.Getpid(), ID: atomic.AddInt64(&nextSlotID, 1)},
+		reserved: tokens,
+	}
+
+	err := permit.wait(ctx, func(state map[string]*bucket) (time.Duration, string) {
+		return permit.take(state, limits)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return permit, nil
+}
+
+// Next waits until the limits allow another request under the permit, such
+// as a retry or the next turn of a tool loop, and takes it from the bucket.
+// The permit's slot and tokens already cover it.
+func (p *Permit) Next(ctx context.Context) error {
+	if p.limits.RequestsPerMinute <= 0 {
+		return nil
+	}
+
+	p.Reason = ""
+	return p.wait(ctx, p.takeRequest)
+}
+
+// wait applies take to the shared state until it finds nothing to wait for,
+// recording how long it waited and why
+func (p *Permit) wait(ctx context.Context, take func(state map[string]*bucket) (time.Duration, string)) error {
+	startTime := time.Now()
+	for {
+		var delay time.Duration
+		var reason string
+		err := p.limiter.update(func(state map[string]*bucket) {
+			delay, reason = take(state)
+		})
+		if err != nil {
+			return err
+		}
+
+		if delay == 0 {
+			p.Waited = time.Since(startTime)
+			return nil
+		}
+		p.Reason = reason
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// take takes the permit's request, tokens and slot from the bucket if all
+// are available. Otherwise it returns how long to wait before trying again,
+// and the limit waited for.
+func (p *Permit) take(state map[string]*bucket, limits Limits) (time.Duration, string) {
+	now := time.Now()
+	b := bucketOf(state, p.name, limits, now)
+	b.pruneSlots(now)
+
+	if limits.RequestsPerMinute > 0 && b.Requests < 1 {
+		return perMinute(1-b.Requests, limits.RequestsPerMinute), ReasonRequests
+	}
+	if limits.TokensPerMinute > 0 && b.Tokens < float64(p.reserved) {
+		return perMinute(float64(p.reserved)-b.Tokens, limits.TokensPerMinute), ReasonTokens
+	}
+	if limits.MaxConcurrent > 0 && len(b.Slots) >= limits.MaxConcurrent {
+		return pollInterval, ReasonConcurrent
+	}
+
+	if limits.RequestsPerMinute > 0 {
+		b.Requests--
+	}
+	if limits.TokensPerMinute > 0 {
+		b.Tokens -= float64(p.reserved)
+	}
+	if limits.MaxConcurrent > 0 {
+		p.slot.Since = now
+		b.Slots = append(b.Slots, p.slot)
+	}
+	return 0, ""
+}
+
+// takeRequest takes one more request of the permit from the bucket, or
+// returns how long to wait for one
+func (p *Permit) takeRequest(state map[string]*bucket) (time.Duration, string) {
+	b := bucketOf(state, p.name, p.limits, time.Now())
+	if b.Requests < 1 {
+		return perMinute(1-b.Requests, p.limits.RequestsPerMinute), ReasonRequests
+	}
+	b.Requests--
+	return 0, ""
+}
+
+// bucketOf returns the named provider's bucket, full when it is new, and
+// refilled to now
+func bucketOf(state map[string]*bucket, name string, limits Limits, now time.Time) *bucket {
+	b := state[name]
+	if b == nil {
+		b = &bucket{Requests: float64(limits.RequestsPerMinute), Tokens: float64(limits.TokensPerMinute), Updated: now}
+		state[name] = b
+	}
+	b.refill(limits, now)
+	return b
+}
+
+// Release returns the permit's slot and corrects the tokens taken to the
+// tokens the request used. A negative count keeps the reservation.
+func (p *Permit) Release(tokensUsed int) {
+	if p.slot.Since.IsZero() && p.reserved == 0 {
+		return
+	}
+
+	// A failure to release only delays later requests until the slot is
+	// found stale
+	p.limiter.update(func(state map[string]*bucket) {
+		b := state[p.name]
+		if b == nil {
+			return
+		}
+
+		for i, s := range b.Slots {
+			if s.PID == p.slot.PID && s.ID == p.slot.ID {
+				b.Slots = append(b.Slots[:i], b.Slots[i+1:]...)
+				break
+			}
+		}
+
+		if tokensUsed >= 0 && p.reserved > 0 {
+			b.Tokens += float64(p.reserved - tokensUsed)
+		}
+	})
+}
+
+// refill adds the requests and tokens accumulated since the last update, up
+// to a minute's worth
+func (b *bucket) refill(limits Limits, now time.Time) {
+	elapsed := now.Sub(b.Updated).Minutes()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	b.Requests = math.Min(b.Requests+elapsed*float64(limits.RequestsPerMinute), float64(limits.RequestsPerMinute))
+	b.Tokens = math.Min(b.Tokens+elapsed*float64(limits.TokensPerMinute), float64(limits.TokensPerMinute))
+	b.Updated = now
+}
+
+// pruneSlots drops the slots of processes that exited without releasing
+// them
+func (b *bucket) pruneSlots(now time.Time) {
+	var live []slot
+	for _, s := range b.Slots {
+		if now.Sub(s.Since) < staleSlot && processAlive(s.PID) {
+			live = append(live, s)
+		}
+	}
+	b.Slots = live
+}
+
+// perMinute returns how long a limit of rate per minute takes to refill n
+func perMinute(n float64, rate int) time.Duration {
+	return time.Duration(n / float64(rate) * float64(time.Minute))
+}
+
+// update applies fn to the shared state while holding the lock on its file
+func (l *Limiter) update(fn func(state map[string]*bucket)) error {
+	err := filelock.Update(l.path, func(data []byte) ([]byte, error) {
+		// Start over from a corrupted file rather than blocking requests
+		state := make(map[string]*bucket)
+		if len(data) > 0 && json.Unmarshal(data, &state) != nil {
+			state = make(map[string]*bucket)
+		}
+
+		fn(state)
+
+		return json.Marshal(state)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rate limit state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/providers/anthropic"
+	"github.com/Codilas/how/pkg/providers/mock"
+)
+
+func newTestLimiter(t *testing.T) *Limiter {
+	t.Helper()
+	return NewLimiter(filepath.Join(t.TempDir(), "ratelimit.json"))
+}
+
+func newPermit(name string, reserved int) *Permit {
+	return &Permit{name: name, slot: slot{PID: os.Getpid(), ID: 1}, reserved: reserved}
+}
+
+func TestPerMinute(t *testing.T) {
+	if d := perMinute(1, 60); d != time.Second {
+		t.Errorf("perMinute(1, 60) = %s, want 1s", d)
+	}
+	if d := perMinute(500, 1000); d != 30*time.Second {
+		t.Errorf("perMinute(500, 1000) = %s, want 30s", d)
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	limits := Limits{RequestsPerMinute: 60, TokensPerMinute: 1000}
+	now := time.Now()
+
+	b := &bucket{Requests: 0, Tokens: -200, Updated: now.Add(-30 * time.Second)}
+	b.refill(limits, now)
+	if b.Requests != 30 || b.Tokens != 300 || !b.Updated.Equal(now) {
+		t.Errorf("after 30s: %+v, want 30 requests and 300 tokens", b)
+	}
+
+	b.refill(limits, now.Add(10*time.Minute))
+	if b.Requests != 60 || b.Tokens != 1000 {
+		t.Errorf("after 10m: %+v, want the buckets full", b)
+	}
+
+	// A clock going backwards adds nothing
+	b = &bucket{Requests: 1, Updated: now.Add(time.Minute)}
+	b.refill(limits, now)
+	if b.Requests != 1 {
+		t.Errorf("requests = %g after the clock went back, want 1", b.Requests)
+	}
+}
+
+func TestPermitTake(t *testing.T) {
+	t.Run("requests per minute", func(t *testing.T) {
+		state := make(map[string]*bucket)
+		limits := Limits{RequestsPerMinute: 2}
+
+		for i := 0; i < 2; i++ {
+			if delay, _ := newPermit("claude", 0).take(state, limits); delay != 0 {
+				t.Fatalf("request %d waited %s", i+1, delay)
+			}
+		}
+
+		delay, reason := newPermit("claude", 0).take(state, limits)
+		if reason != ReasonRequests || delay < 29*time.Second || delay > 30*time.Second {
+			t.Errorf("third request: wait %s for %q, want about 30s for %q", delay, reason, ReasonRequests)
+		}
+
+		if delay, _ := newPermit("openai", 0).take(state, limits); delay != 0 {
+			t.Errorf("another provider waited %s", delay)
+		}
+	})
+
+	t.Run("tokens per minute", func(t *testing.T) {
+		state := make(map[string]*bucket)
+		limits := Limits{TokensPerMinute: 1000}
+
+		if delay, _ := newPermit("claude", 600).take(state, limits); delay != 0 {
+			t.Fatalf("first request waited %s", delay)
+		}
+
+		// 400 tokens are left; 200 more take 12s to refill
+		delay, reason := newPermit("claude", 600).take(state, limits)
+		if reason != ReasonTokens || delay < 11*time.Second || delay > 12*time.Second {
+			t.Errorf("second request: wait %s for %q, want about 12s for %q", delay, reason, ReasonTokens)
+		}
+	})
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		state := make(map[string]*bucket)
+		limits := Limits{MaxConcurrent: 1}
+
+		if delay, _ := newPermit("claude", 0).take(state, limits); delay != 0 {
+			t.Fatalf("first request waited %s", delay)
+		}
+		delay, reason := newPermit("claude", 0).take(state, limits)
+		if reason != ReasonConcurrent || delay != pollInterval {
+			t.Errorf("second request: wait %s for %q, want %s for %q", delay, reason, pollInterval, ReasonConcurrent)
+		}
+	})
+}
+
+func TestPruneSlots(t *testing.T) {
+	now := time.Now()
+	b := &bucket{Slots: []slot{
+		{PID: os.Getpid(), ID: 1, Since: now},
+		{PID: os.Getpid(), ID: 2, Since: now.Add(-2 * staleSlot)},
+		{PID: math.MaxInt32, ID: 3, Since: now},
+	}}
+
+	b.pruneSlots(now)
+	if len(b.Slots) != 1 || b.Slots[0].ID != 1 {
+		t.Errorf("slots = %+v, want only the live, recent one", b.Slots)
+	}
+}
+
+func TestAcquireAndRelease(t *testing.T) {
+	limiter := newTestLimiter(t)
+	limits := Limits{TokensPerMinute: 1000, MaxConcurrent: 1}
+
+	permit, err := limiter.Acquire(context.Background(), "claude", limits, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The slot is taken until the permit is released
+	ctx, cancel := context.WithTimeout(context.Background(), 3*pollInterval)
+	defer cancel()
+	if _, err := limiter.Acquire(ctx, "claude", limits, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire with the slot taken = %v, want to wait until the deadline", err)
+	}
+
+	// Only 100 of the 600 tokens reserved were used
+	permit.Release(100)
+
+	var b bucket
+	limiter.update(func(state map[string]*bucket) { b = *state["claude"] })
+	if len(b.Slots) != 0 {
+		t.Errorf("slots = %+v after release, want none", b.Slots)
+	}
+	if b.Tokens < 899 || b.Tokens > 1000 {
+		t.Errorf("tokens = %g after release, want about 900", b.Tokens)
+	}
+
+	// A request larger than the bucket waits for a full bucket, not forever
+	permit, err = limiter.Acquire(context.Background(), "openai", limits, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if permit.reserved != 1000 {
+		t.Errorf("reserved %d tokens, want the bucket size", permit.reserved)
+	}
+}
+
+func TestProviderReleasesPermits(t *testing.T) {
+	provider, err := mock.NewProvider(providers.Config{Type: "mock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter := newTestLimiter(t)
+	var waits int
+	limited := Wrap("local", providers.AsV2(provider), limiter, Limits{MaxConcurrent: 1}, func(string, *Permit) { waits++ })
+
+	for i := 0; i < 3; i++ {
+		if _, err := limited.Send(context.Background(), providers.NewRequest("hello", nil)); err != nil {
+			t.Fatal(err)
+		}
+
+		stream, err := limited.Stream(context.Background(), providers.NewRequest("hello", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range stream {
+		}
+	}
+
+	if waits != 0 {
+		t.Errorf("sequential requests waited %d times, want the slot released after each", waits)
+	}
+}
+
+type listTool struct{}
+
+func (listTool) Definition() providers.ToolDefinition {
+	return providers.ToolDefinition{Name: "list_directory", InputSchema: map[string]interface{}{"type": "object"}}
+}
+
+func (listTool) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	return "main.go", nil
+}
+
+func TestProviderCountsEveryRequest(t *testing.T) {
+	// The API is overloaded once, then calls a tool twice before answering
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch call := atomic.AddInt32(&calls, 1); call {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+		case 2, 3:
+			fmt.Fprintf(w, `{"role":"assistant","model":"claude-sonnet-4","stop_reason":"tool_use",
+				"content":[{"type":"tool_use","id":"tool-%d","name":"list_directory","input":{}}],
+				"usage":{"input_tokens":100,"output_tokens":10}}`, call)
+		default:
+			fmt.Fprint(w, `{"role":"assistant","model":"claude-sonnet-4","stop_reason":"end_turn",
+				"content":[{"type":"text","text":"There is one file."}],
+				"usage":{"input_tokens":100,"output_tokens":10}}`)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := anthropic.NewProvider(providers.Config{
+		Type:      "anthropic",
+		APIKey:    "test",
+		Model:     "claude-sonnet-4",
+		BaseURL:   server.URL,
+		MaxTokens: 1000,
+		Retry:     &providers.RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter := newTestLimiter(t)
+	limits := Limits{RequestsPerMinute: 60}
+	limited := Wrap("claude", providers.AsV2(provider), limiter, limits, nil)
+
+	req := providers.NewRequest("what is in this directory", nil)
+	req.Tools = []providers.Tool{listTool{}}
+	resp, err := limited.Send(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "There is one file." || calls != 4 {
+		t.Fatalf("answer %q after %d calls, want the final answer after 4", resp.Text, calls)
+	}
+
+	var b bucket
+	limiter.update(func(state map[string]*bucket) { b = *state["claude"] })
+	if math.Abs(b.Requests-56) > 0.5 {
+		t.Errorf("%.1f requests left, want one used by each of the 4 calls", b.Requests)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package ratelimit
+
+import "golang.org/x/sys/windows"
+
+// stillActive is the exit code of a process that has not exited
+const stillActive = 259
+
+// processAlive reports whether the process with the given ID is running
+func processAlive(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(handle)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
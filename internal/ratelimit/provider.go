@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider queues the requests to the provider it wraps until its limits
+// allow them
+type Provider struct {
+	providers.ProviderV2
+
+	name    string
+	limiter *Limiter
+	limits  Limits
+	onWait  func(name string, permit *Permit)
+}
+
+// Wrap returns a provider that keeps the requests to next, which is
+// configured under name, within limits. onWait, if set, is called for every
+// request that had to wait.
+func Wrap(name string, next providers.ProviderV2, limiter *Limiter, limits Limits, onWait func(name string, permit *Permit)) *Provider {
+	return &Provider{
+		ProviderV2: next,
+		name:       name,
+		limiter:    limiter,
+		limits:     limits,
+		onWait:     onWait,
+	}
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	permit, err := p.acquire(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.ProviderV2.Send(p.countRequests(ctx, permit), req)
+	if permit != nil {
+		if err != nil {
+			permit.Release(-1)
+		} else {
+			permit.Release(tokensUsed(resp.InputTokens, resp.OutputTokens))
+		}
+	}
+
+	return resp, err
+}
+
+// Stream implements the providers.ProviderV2 interface, holding the permit
+// until the stream ends
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	permit, err := p.acquire(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := p.ProviderV2.Stream(p.countRequests(ctx, permit), req)
+	if err != nil {
+		if permit != nil {
+			permit.Release(-1)
+		}
+		return nil, err
+	}
+	if permit == nil {
+		return in, nil
+	}
+
+	out := make(chan providers.StreamResponse)
+	go func() {
+		defer close(out)
+
+		used := -1
+		defer func() { permit.Release(used) }()
+
+		for chunk := range in {
+			if chunk.Done && chunk.Error == nil {
+				inputTokens, _ := chunk.Metadata[providers.MetadataInputTokens].(int)
+				outputTokens, _ := chunk.Metadata[providers.MetadataOutputTokens].(int)
+				used = tokensUsed(inputTokens, outputTokens)
+			}
+
+			if !providers.SendChunk(ctx, out, chunk) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// acquire waits for a permit to send req. Requests are sent without a permit
+// when the shared state cannot be used, since the limiter must never stop
+// requests on its own.
+func (p *Provider) acquire(ctx context.Context, req *providers.Request) (*Permit, error) {
+	tokens, err := providers.EstimateRequestTokens(req)
+	if err != nil {
+		tokens = providers.EstimateTokens(req.Prompt())
+	}
+
+	permit, err := p.limiter.Acquire(ctx, p.name, p.limits, tokens)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+
+	if permit.Reason != "" && p.onWait != nil {
+		p.onWait(p.name, permit)
+	}
+
+	return permit, nil
+}
+
+// countRequests returns a context in which every request sent under permit
+// after the first, such as retries and the turns of a tool loop, waits for
+// and uses up the requests per minute limit like the first did
+func (p *Provider) countRequests(ctx context.Context, permit *Permit) context.Context {
+	if permit == nil {
+		return ctx
+	}
+
+	var sent int32
+	return providers.WithRequestHook(ctx, func(ctx context.Context) error {
+		if atomic.AddInt32(&sent, 1) == 1 {
+			return nil
+		}
+
+		if err := permit.Next(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return nil
+		}
+
+		if permit.Reason != "" && p.onWait != nil {
+			p.onWait(p.name, permit)
+		}
+		return nil
+	})
+}
+
+// tokensUsed returns the tokens a request used, or -1 when the provider did
+// not report them
+func tokensUsed(inputTokens, outputTokens int) int {
+	if inputTokens == 0 && outputTokens == 0 {
+		return -1
+	}
+	return inputTokens + outputTokens
+}
@@ -0,0 +1,59 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when a spending cap has been reached
+var ErrBudgetExceeded = fmt.Errorf("spending cap reached")
+
+// Budget caps spending in US dollars per calendar day and month, in local
+// time. A zero cap is unlimited.
+type Budget struct {
+	Daily   float64
+	Monthly float64
+}
+
+// StartOfDay returns midnight of the day of t
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfMonth returns midnight of the first day of the month of t
+func StartOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Check returns ErrBudgetExceeded when the spending recorded in the ledger
+// has reached a cap as of now
+func (b Budget) Check(ledger *Ledger, now time.Time) error {
+	if b.Daily <= 0 && b.Monthly <= 0 {
+		return nil
+	}
+
+	records, err := ledger.Records(StartOfMonth(now))
+	if err != nil {
+		return err
+	}
+
+	today := StartOfDay(now)
+	var daily, monthly float64
+	for _, record := range records {
+		monthly += record.Cost
+		if !record.Time.Before(today) {
+			daily += record.Cost
+		}
+	}
+
+	if b.Daily > 0 && daily >= b.Daily {
+		return fmt.Errorf("%w: $%.2f spent today, the daily cap is $%.2f", ErrBudgetExceeded, daily, b.Daily)
+	}
+	if b.Monthly > 0 && monthly >= b.Monthly {
+		return fmt.Errorf("%w: $%.2f spent this month, the monthly cap is $%.2f", ErrBudgetExceeded, monthly, b.Monthly)
+	}
+
+	return nil
+}
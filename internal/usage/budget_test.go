@@ -0,0 +1,154 @@
+package usage
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLedger(t *testing.T, records ...Record) *Ledger {
+	t.Helper()
+
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage", "ledger.jsonl"))
+	for _, record := range records {
+		if err := ledger.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ledger
+}
+
+func TestBudgetCheck(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	ledger := newTestLedger(t,
+		Record{Time: time.Date(2026, 2, 28, 23, 0, 0, 0, time.Local), Cost: 100}, // last month
+		Record{Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), Cost: 6},
+		Record{Time: time.Date(2026, 3, 14, 23, 59, 0, 0, time.Local), Cost: 2},
+		Record{Time: time.Date(2026, 3, 15, 0, 0, 0, 0, time.Local), Cost: 1.5},
+		Record{Time: time.Date(2026, 3, 15, 11, 0, 0, 0, time.Local), Cost: 0.5},
+	)
+
+	tests := []struct {
+		name     string
+		budget   Budget
+		exceeded bool
+	}{
+		{"unlimited", Budget{}, false},
+		{"under daily cap", Budget{Daily: 2.5}, false},
+		{"daily cap reached", Budget{Daily: 2}, true},
+		{"under monthly cap", Budget{Monthly: 10.5}, false},
+		{"monthly cap reached", Budget{Monthly: 10}, true},
+		{"both under", Budget{Daily: 5, Monthly: 50}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.budget.Check(ledger, now)
+			if tt.exceeded && !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("Check = %v, want the cap reached", err)
+			}
+			if !tt.exceeded && err != nil {
+				t.Errorf("Check = %v, want no error", err)
+			}
+		})
+	}
+}
+
+func TestBudgetCheckWithoutLedger(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err := (Budget{Daily: 1}).Check(ledger, time.Now()); err != nil {
+		t.Errorf("Check without a ledger = %v, want no error", err)
+	}
+}
+
+func TestStartOfPeriods(t *testing.T) {
+	at := time.Date(2026, 3, 15, 18, 30, 5, 0, time.Local)
+
+	if got := StartOfDay(at); !got.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("StartOfDay = %s", got)
+	}
+	if got := StartOfMonth(at); !got.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("StartOfMonth = %s", got)
+	}
+}
+
+func TestLedgerRecords(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ledger := newTestLedger(t,
+		Record{Time: start.Add(-time.Second), Provider: "old", Cost: 1},
+		Record{Time: start, Provider: "claude", Cost: 0.25},
+		Record{Time: start.Add(time.Hour), Provider: "openai", Cost: 0.5},
+	)
+
+	// A line cut short by a crash is skipped
+	file, err := os.OpenFile(ledger.Path(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2026-03-01T02:00:00Z","provid` + "\n")
+	file.Close()
+
+	records, err := ledger.Records(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Provider != "claude" || records[1].Provider != "openai" {
+		t.Errorf("records = %+v, want claude and openai in order", records)
+	}
+
+	spent, err := ledger.Spent(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 0.75 {
+		t.Errorf("spent = %g, want 0.75", spent)
+	}
+}
+
+func TestLedgerConcurrentAppends(t *testing.T) {
+	ledger := newTestLedger(t)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ledger.Append(Record{Time: time.Now(), Provider: "claude", Cost: 0.01})
+		}()
+	}
+	wg.Wait()
+
+	records, err := ledger.Records(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != writers {
+		t.Errorf("read %d records, want %d", len(records), writers)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	price := Price{Input: 3, Output: 15}
+
+	if cost := price.Cost(1000000, 100000); math.Abs(cost-4.5) > 1e-9 {
+		t.Errorf("Cost = %g, want 4.5", cost)
+	}
+	if cost := price.CacheCost(1000000, 1000000); math.Abs(cost-(0.3+3.75)) > 1e-9 {
+		t.Errorf("CacheCost = %g, want 4.05", cost)
+	}
+
+	if p, ok := PriceOf("anthropic", "claude-sonnet-4-20250514"); !ok || p != price {
+		t.Errorf("PriceOf(claude-sonnet-4) = %+v, %v", p, ok)
+	}
+	if p, ok := PriceOf("ollama", "llama3"); !ok || p != (Price{}) {
+		t.Errorf("PriceOf(ollama) = %+v, %v, want free", p, ok)
+	}
+	if _, ok := PriceOf("openai", "unknown-model"); ok {
+		t.Error("unknown model priced")
+	}
+}
@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Record is the usage of a single request
+type Record struct {
+	Time         time.Time `json:"time"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+
+	// Input tokens served from and written to the provider's prompt cache
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+
+	// Cost in US dollars, computed when the request was made. Unpriced is
+	// set when the model's price was unknown and Cost is zero.
+	Cost     float64 `json:"cost"`
+	Unpriced bool    `json:"unpriced,omitempty"`
+}
+
+// Ledger is an append-only file of usage records, one JSON object per line
+type Ledger struct {
+	path string
+}
+
+// NewLedger creates a ledger stored at path
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Path returns the location of the ledger file
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds a record to the ledger. Each record is written with a single
+// append, so that concurrent invocations do not interleave lines.
+func (l *Ledger) Append(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+
+	return nil
+}
+
+// Records returns the records made at or after since, oldest first.
+// Malformed lines, such as one cut short by a crash, are skipped.
+func (l *Ledger) Records(since time.Time) ([]Record, error) {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	return records, nil
+}
+
+// Spent returns the total cost of the records made at or after since
+func (l *Ledger) Spent(since time.Time) (float64, error) {
+	records, err := l.Records(since)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, record := range records {
+		total += record.Cost
+	}
+	return total, nil
+}
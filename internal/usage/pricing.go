@@ -0,0 +1,47 @@
+package usage
+
+import "github.com/Codilas/how/pkg/providers"
+
+// Price is the price of a model in US dollars per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the cost of a request with the given token counts
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// Prompt cache prices relative to the input price
+const (
+	cacheReadRate  = 0.1
+	cacheWriteRate = 1.25
+)
+
+// CacheCost returns the cost of input tokens read from and written to a
+// provider's prompt cache
+func (p Price) CacheCost(readTokens, writeTokens int) float64 {
+	return (float64(readTokens)*cacheReadRate + float64(writeTokens)*cacheWriteRate) * p.Input / 1e6
+}
+
+// freeTypes are provider types that run locally and cost nothing
+var freeTypes = map[string]bool{
+	"ollama": true,
+	"mock":   true,
+}
+
+// PriceOf returns the price of a model served by a provider of the given
+// type from the model registry, and whether it is known
+func PriceOf(providerType, model string) (Price, bool) {
+	if freeTypes[providerType] {
+		return Price{}, true
+	}
+
+	info, ok := providers.LookupModel(model)
+	if !ok || !info.Priced() {
+		return Price{}, false
+	}
+
+	return Price{Input: info.InputPrice, Output: info.OutputPrice}, true
+}
@@ -0,0 +1,114 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider records the usage of every request answered by the provider it
+// wraps in a Ledger
+type Provider struct {
+	providers.ProviderV2
+
+	name   string
+	ledger *Ledger
+	price  *Price
+}
+
+// Wrap returns a provider that records the usage of next, which is
+// configured under name. price overrides the built-in price list when set.
+func Wrap(name string, next providers.ProviderV2, ledger *Ledger, price *Price) *Provider {
+	return &Provider{
+		ProviderV2: next,
+		name:       name,
+		ledger:     ledger,
+		price:      price,
+	}
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	resp, err := p.ProviderV2.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.Cached {
+		p.record(resp.Model, Record{
+			InputTokens:      resp.InputTokens,
+			OutputTokens:     resp.OutputTokens,
+			CacheReadTokens:  resp.CacheReadTokens,
+			CacheWriteTokens: resp.CacheWriteTokens,
+		})
+	}
+
+	return resp, nil
+}
+
+// Stream implements the providers.ProviderV2 interface, recording the token
+// counts reported in the final chunk
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	in, err := p.ProviderV2.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan providers.StreamResponse)
+	go func() {
+		defer close(out)
+
+		for chunk := range in {
+			if chunk.Done && chunk.Error == nil {
+				if cached, _ := chunk.Metadata[providers.MetadataCached].(bool); !cached {
+					model, _ := chunk.Metadata[providers.MetadataModel].(string)
+					p.record(model, streamRecord(chunk.Metadata))
+				}
+			}
+
+			if !providers.SendChunk(ctx, out, chunk) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// streamRecord reads the token counts of a stream from its final chunk
+func streamRecord(metadata map[string]interface{}) Record {
+	var record Record
+	record.InputTokens, _ = metadata[providers.MetadataInputTokens].(int)
+	record.OutputTokens, _ = metadata[providers.MetadataOutputTokens].(int)
+	record.CacheReadTokens, _ = metadata[providers.MetadataCacheReadTokens].(int)
+	record.CacheWriteTokens, _ = metadata[providers.MetadataCacheWriteTokens].(int)
+	return record
+}
+
+// record completes a record of the token counts of a request and appends
+// it to the ledger
+func (p *Provider) record(model string, record Record) {
+	info := p.GetInfo()
+	if model == "" {
+		model = info.Model
+	}
+
+	record.Time = time.Now()
+	record.Provider = p.name
+	record.Model = model
+
+	price, ok := PriceOf(info.Type, model)
+	if p.price != nil {
+		price, ok = *p.price, true
+	}
+	if ok {
+		record.Cost = price.Cost(record.InputTokens, record.OutputTokens) +
+			price.CacheCost(record.CacheReadTokens, record.CacheWriteTokens)
+	} else {
+		record.Unpriced = true
+	}
+
+	// A failure to record must not fail the request
+	p.ledger.Append(record)
+}
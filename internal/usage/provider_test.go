@@ -0,0 +1,59 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+	"github.com/Codilas/how/pkg/providers/mock"
+)
+
+func newMockProvider(t *testing.T) providers.ProviderV2 {
+	t.Helper()
+
+	provider, err := mock.NewProvider(providers.Config{Type: "mock", Model: "mock-model"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return providers.AsV2(provider)
+}
+
+func TestProviderRecordsUsage(t *testing.T) {
+	ledger := newTestLedger(t)
+	provider := Wrap("local", newMockProvider(t), ledger, &Price{Input: 1, Output: 2})
+
+	resp, err := provider.Send(context.Background(), providers.NewRequest("hello", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := provider.Stream(context.Background(), providers.NewRequest("hello again", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for chunk := range stream {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+	}
+
+	records, err := ledger.Records(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("recorded %d requests, want 2", len(records))
+	}
+
+	first := records[0]
+	if first.Provider != "local" || first.Model != "mock-model" || first.InputTokens != resp.InputTokens || first.OutputTokens != resp.OutputTokens {
+		t.Errorf("record = %+v, want the response's usage", first)
+	}
+	if want := (Price{Input: 1, Output: 2}).Cost(resp.InputTokens, resp.OutputTokens); first.Cost != want || first.Unpriced {
+		t.Errorf("cost = %g (unpriced %v), want %g", first.Cost, first.Unpriced, want)
+	}
+	if records[1].InputTokens == 0 {
+		t.Errorf("stream record = %+v, want the token counts of the final chunk", records[1])
+	}
+}
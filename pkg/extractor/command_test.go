@@ -0,0 +1,71 @@
+package extractor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func commandLines(commands []Command) []string {
+	var lines []string
+	for _, cmd := range commands {
+		lines = append(lines, cmd.Command)
+	}
+	return lines
+}
+
+func TestExtractStructured(t *testing.T) {
+	text := "Run this:\n\n```bash\nls\n```\n\n<structured_commands>\n" + `{
+  "commands": [{"command": "ls -la", "description": "List files", "safe": true, "order": 1}],
+  "workflows": [{"name": "release", "steps": [
+    {"command": "git tag v1.0.0", "order": 1},
+    {"command": "git push --tags", "order": 2}
+  ]}]
+}` + "\n</structured_commands>"
+
+	commands, err := NewCommandExtractor().Extract(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The structured section wins over the code blocks
+	if got := commandLines(commands.GetAllCommands()); !reflect.DeepEqual(got, []string{"ls -la", "git tag v1.0.0", "git push --tags"}) {
+		t.Errorf("commands = %q", got)
+	}
+	if commands.Count() != 3 || !commands.HasCommands() {
+		t.Errorf("Count = %d, HasCommands = %v", commands.Count(), commands.HasCommands())
+	}
+	if cmd := commands.Commands[0]; cmd.Description != "List files" || !cmd.Safe {
+		t.Errorf("command = %+v, want its description and safety kept", cmd)
+	}
+}
+
+func TestExtractFromCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"no commands", "Nothing to run here.", nil},
+		{"bash block", "```bash\ngit status\ngit diff\n```", []string{"git status", "git diff"}},
+		{"prompts and comments", "```sh\n# check first\n$ df -h\n\n> du -sh .\n```", []string{"df -h", "du -sh ."}},
+		{"several blocks", "```\nmake\n```\nthen\n```shell\nmake test\n```", []string{"make", "make test"}},
+		{"invalid structured section", "<structured_commands>not json</structured_commands>\n```bash\nls\n```", []string{"ls"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := NewCommandExtractor().Extract(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commandLines(commands.Commands); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commands = %q, want %q", got, tt.want)
+			}
+			for i, cmd := range commands.Commands {
+				if cmd.Order != i+1 {
+					t.Errorf("command %q has order %d, want %d", cmd.Command, cmd.Order, i+1)
+				}
+			}
+		})
+	}
+}
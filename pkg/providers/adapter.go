@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"context"
+)
+
+// AsV2 returns p as a ProviderV2. Providers that only implement the legacy
+// interface are wrapped in an adapter, which converts requests back to a
+// prompt and context, ignores the request options and abandons the call when
+// ctx is cancelled.
+func AsV2(p Provider) ProviderV2 {
+	if v2, ok := p.(ProviderV2); ok {
+		return v2
+	}
+	return &legacyAdapter{Provider: p}
+}
+
+// legacyAdapter implements ProviderV2 on top of the legacy Provider methods
+type legacyAdapter struct {
+	Provider
+}
+
+// Send implements the ProviderV2 interface
+func (a *legacyAdapter) Send(ctx context.Context, req *Request) (*Response, error) {
+	prompt, promptContext := legacyArgs(req)
+
+	type result struct {
+		resp *Response
+		err  error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		resp, err := a.SendPrompt(prompt, promptContext)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stream implements the ProviderV2 interface
+func (a *legacyAdapter) Stream(ctx context.Context, req *Request) (<-chan StreamResponse, error) {
+	prompt, promptContext := legacyArgs(req)
+
+	in, err := a.SendPromptStream(prompt, promptContext)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamResponse)
+	go func() {
+		defer close(out)
+		for chunk := range in {
+			if !SendChunk(ctx, out, chunk) {
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// legacyArgs converts a request to the arguments of the legacy methods,
+// folding earlier message pairs back into the context's previous prompts
+func legacyArgs(req *Request) (string, *Context) {
+	promptContext := &Context{}
+	if req.Context != nil {
+		copied := *req.Context
+		promptContext = &copied
+	}
+
+	var history []HistoryEntry
+	for i := 0; i+1 < len(req.Messages)-1; i += 2 {
+		if req.Messages[i].Role == RoleUser && req.Messages[i+1].Role == RoleAssistant {
+			history = append(history, HistoryEntry{
+				Prompt:   req.Messages[i].Content,
+				Response: req.Messages[i+1].Content,
+			})
+		}
+	}
+	promptContext.PreviousPrompts = history
+
+	return req.Prompt(), promptContext
+}
@@ -2,10 +2,13 @@ package anthropic
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Codilas/how/pkg/providers"
@@ -20,16 +23,33 @@ type Provider struct {
 
 // API request/response structures
 type request struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	Messages  []message `json:"messages"`
-	System    string    `json:"system,omitempty"`
-	Stream    bool      `json:"stream,omitempty"`
+	Model         string         `json:"model"`
+	MaxTokens     int            `json:"max_tokens"`
+	Messages      []message      `json:"messages"`
+	System        []contentBlock `json:"system,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	Temperature   *float32       `json:"temperature,omitempty"`
+	TopP          *float32       `json:"top_p,omitempty"`
+	StopSequences []string       `json:"stop_sequences,omitempty"`
+
+	Tools      []providers.ToolDefinition `json:"tools,omitempty"`
+	ToolChoice *toolChoice                `json:"tool_choice,omitempty"`
+
+	Thinking *thinkingConfig `json:"thinking,omitempty"`
+}
+
+type thinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type toolChoice struct {
+	Type string `json:"type"`
 }
 
 type response struct {
@@ -45,12 +65,46 @@ type response struct {
 
 type contentBlock struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result blocks
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+
+	// image blocks
+	Source *imageSource `json:"source,omitempty"`
+
+	// thinking and redacted_thinking blocks, which must be sent back
+	// unchanged with tool results
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// CacheControl marks the end of a prompt prefix to cache
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type usage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 }
 
 type apiError struct {
@@ -82,8 +136,21 @@ const (
 	ProviderName = "anthropic"
 	baseURL      = "https://api.anthropic.com/v1"
 	version      = "2023-06-01"
+	stopToolUse  = "tool_use"
 	displayName  = "Anthropic Claude AI"
 	description  = "Anthropic's Claude AI assistant, designed for safe and helpful interactions."
+
+	// maxTemperature is the highest sampling temperature the API accepts
+	maxTemperature = 1
+
+	// defaultTimeout limits a request unless a timeout is configured
+	defaultTimeout = 60 * time.Second
+
+	// maxImageSize is the largest image the API accepts
+	maxImageSize = 5 * 1024 * 1024
+
+	// minThinkingBudget is the smallest extended thinking budget
+	minThinkingBudget = 1024
 )
 
 // NewProvider creates a new Anthropic provider instance
@@ -93,61 +160,162 @@ func NewProvider(cfg providers.Config) (providers.Provider, error) {
 		url = cfg.BaseURL
 	}
 
+	httpClient, err := cfg.HTTPClient(defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Provider{
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-		cfg:     cfg,
-		baseURL: url,
+		httpClient: httpClient,
+		cfg:        cfg,
+		baseURL:    url,
 	}, nil
 }
 
 // SendPrompt implements the providers.Provider interface
-func (p *Provider) SendPrompt(prompt string, context *providers.Context) (*providers.Response, error) {
+func (p *Provider) SendPrompt(prompt string, promptContext *providers.Context) (*providers.Response, error) {
+	return p.Send(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// SendPromptStream implements streaming for the providers.Provider interface
+func (p *Provider) SendPromptStream(prompt string, promptContext *providers.Context) (<-chan providers.StreamResponse, error) {
+	return p.Stream(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// Send implements the providers.ProviderV2 interface. When the request
+// offers tools, the tools the model calls are run and their results sent
+// back until the model answers or the turn limit is reached.
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
 	startTime := time.Now()
 
 	// Build the request
-	req, err := p.buildRequest(prompt, context, false)
+	apiReq, err := p.buildRequest(req, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
-	// Create HTTP request
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+	var texts, thoughts []string
+	var total usage
+	var apiResp response
 
-	httpReq, err := http.NewRequest("POST", fmt.Sprint(p.baseURL, "/messages"), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
+	for turn := 0; ; turn++ {
+		apiResp = response{}
+		if err := p.post(ctx, apiReq, &apiResp); err != nil {
+			return nil, err
+		}
 
-	// Send the request
-	var apiResp response
-	if err := p.doRequest(httpReq, &apiResp); err != nil {
-		return nil, err
-	}
+		total.InputTokens += apiResp.Usage.InputTokens
+		total.OutputTokens += apiResp.Usage.OutputTokens
+		total.CacheCreationInputTokens += apiResp.Usage.CacheCreationInputTokens
+		total.CacheReadInputTokens += apiResp.Usage.CacheReadInputTokens
+
+		for _, block := range apiResp.Content {
+			switch {
+			case block.Type == "text" && block.Text != "":
+				texts = append(texts, block.Text)
+			case block.Type == "thinking" && block.Thinking != "":
+				thoughts = append(thoughts, block.Thinking)
+			}
+		}
+
+		if apiResp.StopReason != stopToolUse {
+			break
+		}
 
-	var text string
-	if len(apiResp.Content) > 0 {
-		text = apiResp.Content[0].Text
+		if err := p.appendToolResults(ctx, req, apiReq, apiResp.Content, turn); err != nil {
+			return nil, err
+		}
 	}
 
 	response := &providers.Response{
-		Text:         text,
+		Text:         strings.Join(texts, "\n\n"),
 		Model:        apiResp.Model,
 		Provider:     ProviderName,
-		TokensUsed:   apiResp.Usage.InputTokens + apiResp.Usage.OutputTokens,
+		TokensUsed:   total.InputTokens + total.OutputTokens,
+		InputTokens:  total.InputTokens,
+		OutputTokens: total.OutputTokens,
 		ResponseTime: time.Since(startTime),
+
+		CacheReadTokens:  total.CacheReadInputTokens,
+		CacheWriteTokens: total.CacheCreationInputTokens,
+
+		Thinking: strings.Join(thoughts, "\n\n"),
 	}
 
 	return response, nil
 }
 
-// SendPromptStream implements streaming for the providers.Provider interface
-func (p *Provider) SendPromptStream(prompt string, context *providers.Context) (<-chan providers.StreamResponse, error) {
-	return nil, fmt.Errorf("streaming not yet supported by %s provider", ProviderName)
+// Stream implements the providers.ProviderV2 interface, running tools the
+// same way as Send
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	// Build the request
+	apiReq, err := p.buildRequest(req, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	body, err := p.openStream(ctx, apiReq)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse)
+	go p.streamTurns(ctx, req, apiReq, body, chunks)
+
+	return chunks, nil
+}
+
+// post sends a request to the messages endpoint, retrying transient
+// failures, and decodes the response
+func (p *Provider) post(ctx context.Context, apiReq *request, apiResp *response) error {
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	return p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/messages"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		return p.doRequest(httpReq, apiResp)
+	})
+}
+
+// openStream sends a streaming request to the messages endpoint, retrying
+// transient failures, and returns the event stream. Errors after the first
+// event are reported on the stream and not retried.
+func (p *Provider) openStream(ctx context.Context, apiReq *request) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var resp *http.Response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/messages"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		p.setHeaders(httpReq)
+		httpReq.Header.Set("Accept", "text/event-stream")
+
+		resp, err = providers.StreamClient(p.httpClient).Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return parseErrorResponse(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
 }
 
 // ValidateConfig implements the providers.Provider interface
@@ -160,8 +328,36 @@ func (p *Provider) ValidateConfig() error {
 		return providers.ErrInvalidModel
 	}
 
-	if p.cfg.MaxTokens <= 0 || p.cfg.MaxTokens > 4096 {
-		return fmt.Errorf("max_tokens must be between 1 and 4096, got %d", p.cfg.MaxTokens)
+	if err := p.cfg.ValidateMaxTokens(); err != nil {
+		return err
+	}
+
+	if err := p.cfg.ValidateSampling(maxTemperature); err != nil {
+		return err
+	}
+
+	return p.validateThinking()
+}
+
+// validateThinking checks the extended thinking budget, which counts
+// towards max_tokens, and the sampling settings thinking allows
+func (p *Provider) validateThinking() error {
+	budget := p.cfg.ThinkingBudget
+	if budget == 0 {
+		return nil
+	}
+
+	if budget < minThinkingBudget {
+		return fmt.Errorf("thinking budget must be at least %d tokens, got %d", minThinkingBudget, budget)
+	}
+	if budget >= p.cfg.MaxTokens {
+		return fmt.Errorf("max_tokens (%d) must be greater than the thinking budget (%d)", p.cfg.MaxTokens, budget)
+	}
+	if p.cfg.Temperature != nil && *p.cfg.Temperature != 1 {
+		return fmt.Errorf("temperature cannot be changed with extended thinking")
+	}
+	if p.cfg.TopP != nil && *p.cfg.TopP < 0.95 {
+		return fmt.Errorf("top_p must be at least 0.95 with extended thinking, got %g", *p.cfg.TopP)
 	}
 
 	return nil
@@ -177,17 +373,19 @@ func (p *Provider) GetInfo() providers.ProviderInfo {
 	}
 }
 
-// GetCapabilities implements the providers.Provider interface
+// GetCapabilities implements the providers.Provider interface. Limits come
+// from the model registry, with defaults for unknown models.
 func (p *Provider) GetCapabilities() providers.Capabilities {
-	return providers.Capabilities{
-		Streaming:          false,
-		FunctionCalling:    false,
+	return p.cfg.Capabilities.Apply(providers.ModelCapabilities(p.cfg.Model, providers.Capabilities{
+		Streaming:          true,
+		FunctionCalling:    true,
 		CodeExecution:      false,
-		ImageAnalysis:      false,
+		ImageAnalysis:      true,
 		ConversationMemory: true,
 		MaxContextSize:     200000,
 		MaxTokens:          4096,
-	}
+		MaxImageSize:       maxImageSize,
+	}))
 }
 
 // GetModels implements the providers.Provider interface
@@ -211,67 +409,142 @@ func (p *Provider) GetModels() ([]string, error) {
 	return models, nil
 }
 
+// Ping implements the providers.Pinger interface by looking up the
+// configured model
+func (p *Provider) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprint(p.baseURL, "/models/", url.PathEscape(p.cfg.Model)), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	var info model
+	return p.doRequest(httpReq, &info)
+}
+
 // buildRequest creates an API request
-func (p *Provider) buildRequest(prompt string, context *providers.Context, stream bool) (*request, error) {
+func (p *Provider) buildRequest(req *providers.Request, stream bool) (*request, error) {
 	// Build system prompt with context
-	systemPrompt, err := p.buildSystemPrompt(context)
+	static, dynamic, err := req.SystemPromptParts(p.cfg.SystemPrompt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create the user message
-	userMessage := message{
-		Role:    "user",
-		Content: prompt,
+	messages := make([]message, len(req.Messages))
+	for i, msg := range req.Messages {
+		// Images work best placed before the text that refers to them
+		var content []contentBlock
+		for _, image := range msg.Images {
+			content = append(content, contentBlock{
+				Type: "image",
+				Source: &imageSource{
+					Type:      "base64",
+					MediaType: image.MediaType,
+					Data:      image.Base64(),
+				},
+			})
+		}
+		content = append(content, contentBlock{Type: "text", Text: msg.Content})
+
+		messages[i] = message{Role: msg.Role, Content: content}
 	}
 
-	// Add conversation history if available
-	messages := []message{}
-	if context != nil && len(context.PreviousPrompts) > 0 {
-		for _, entry := range context.PreviousPrompts {
-			messages = append(messages,
-				message{Role: "user", Content: entry.Prompt},
-				message{Role: "assistant", Content: entry.Response},
-			)
+	// Cache the conversation up to the prompt, so that the next question
+	// only pays full price for the latest exchange. The context changes
+	// from one question to the next, so it goes into the prompt: before the
+	// history, it would keep the cached history from ever being read.
+	if !p.cfg.DisablePromptCaching && len(messages) > 2 {
+		history := messages[len(messages)-2].Content
+		history[len(history)-1].CacheControl = &cacheControl{Type: "ephemeral"}
+
+		if prompt := &messages[len(messages)-1]; dynamic != "" && prompt.Role == providers.RoleUser {
+			prompt.Content = append([]contentBlock{{Type: "text", Text: dynamic}}, prompt.Content...)
+			dynamic = ""
 		}
 	}
 
-	messages = append(messages, userMessage)
+	apiReq := &request{
+		Model:         p.cfg.Model,
+		MaxTokens:     req.MaxTokens(p.cfg.MaxTokens),
+		Messages:      messages,
+		System:        p.systemBlocks(static, dynamic),
+		Stream:        stream,
+		Temperature:   req.Temperature(p.cfg.Temperature),
+		TopP:          req.TopP(p.cfg.TopP),
+		StopSequences: req.Options.StopSequences,
+	}
 
-	return &request{
-		Model:     p.cfg.Model,
-		MaxTokens: p.cfg.MaxTokens,
-		Messages:  messages,
-		System:    systemPrompt,
-		Stream:    stream,
-	}, nil
+	for _, tool := range req.Tools {
+		apiReq.Tools = append(apiReq.Tools, tool.Definition())
+	}
+
+	if p.cfg.ThinkingBudget > 0 {
+		apiReq.Thinking = &thinkingConfig{Type: "enabled", BudgetTokens: p.cfg.ThinkingBudget}
+	}
+
+	return apiReq, nil
 }
 
-// buildSystemPrompt creates a system prompt with context information
-func (p *Provider) buildSystemPrompt(context *providers.Context) (string, error) {
-	// Build the system context string
-	systemContext := buildSystemContext(context)
+// systemBlocks builds the system prompt blocks. The static instructions are
+// a block of their own marked for caching, since they are identical for
+// every request, while the context after them changes.
+func (p *Provider) systemBlocks(static, dynamic string) []contentBlock {
+	if p.cfg.DisablePromptCaching {
+		static, dynamic = "", static+dynamic
+	}
 
-	// Create template data
-	data := TemplateData{
-		SystemContext: systemContext,
+	var blocks []contentBlock
+	if static != "" {
+		blocks = append(blocks, contentBlock{Type: "text", Text: static, CacheControl: &cacheControl{Type: "ephemeral"}})
+	}
+	if dynamic != "" {
+		blocks = append(blocks, contentBlock{Type: "text", Text: dynamic})
 	}
+	return blocks
+}
 
-	// Process the template
-	prompt, err := processTemplate(systemPromptTemplate, data)
-	if err != nil {
-		return "", fmt.Errorf("failed to process system prompt template: %w", err)
+// appendToolResults runs the tools called in the model's reply and appends
+// the reply and the results to the conversation. Once the turn limit is
+// reached, the model is asked to answer without further tool calls.
+func (p *Provider) appendToolResults(ctx context.Context, req *providers.Request, apiReq *request, content []contentBlock, turn int) error {
+	limit := req.MaxToolTurns()
+	if turn >= limit {
+		return fmt.Errorf("%w (%d turns)", providers.ErrToolTurnLimit, limit)
 	}
 
-	return prompt, nil
+	var results []contentBlock
+	for _, block := range content {
+		if block.Type != "tool_use" {
+			continue
+		}
+
+		output, isError := req.CallTool(ctx, block.ID, block.Name, block.Input)
+		results = append(results, contentBlock{
+			Type:      "tool_result",
+			ToolUseID: block.ID,
+			Content:   output,
+			IsError:   isError,
+		})
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	apiReq.Messages = append(apiReq.Messages,
+		message{Role: providers.RoleAssistant, Content: content},
+		message{Role: providers.RoleUser, Content: results},
+	)
+
+	if turn+1 >= limit {
+		apiReq.ToolChoice = &toolChoice{Type: "none"}
+	}
+
+	return nil
 }
 
 // doRequest sends an HTTP request and parses the response into the provided struct
 func (p *Provider) doRequest(httpReq *http.Request, response interface{}) error {
-	// Add common headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", p.cfg.APIKey)
-	httpReq.Header.Set("anthropic-version", version)
+	p.setHeaders(httpReq)
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
@@ -280,12 +553,7 @@ func (p *Provider) doRequest(httpReq *http.Request, response interface{}) error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		var errorResp errorResponse
-		if json.Unmarshal(body, &errorResp) == nil {
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, errorResp.Error.Message)
-		}
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return parseErrorResponse(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
@@ -294,3 +562,37 @@ func (p *Provider) doRequest(httpReq *http.Request, response interface{}) error
 
 	return nil
 }
+
+// setHeaders adds the headers common to all API requests
+func (p *Provider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.cfg.APIKey)
+	httpReq.Header.Set("anthropic-version", version)
+
+	for key, value := range p.cfg.CustomHeaders {
+		httpReq.Header.Set(key, value)
+	}
+}
+
+// errorTypeStatus maps API error types to their HTTP status, for errors
+// delivered as stream events rather than responses
+var errorTypeStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+// parseErrorResponse converts a non-200 API response into a typed error
+func parseErrorResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var errorResp errorResponse
+	if json.Unmarshal(body, &errorResp) == nil && errorResp.Error.Message != "" {
+		return providers.NewAPIError(ProviderName, resp.StatusCode, resp.Header, errorResp.Error.Type, errorResp.Error.Message)
+	}
+	return providers.NewAPIError(ProviderName, resp.StatusCode, resp.Header, "", string(body))
+}
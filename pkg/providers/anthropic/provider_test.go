@@ -0,0 +1,88 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// conversationRequest is a follow-up question asked with the given git status
+func conversationRequest(status string) *providers.Request {
+	return providers.NewRequest("and how do I undo it?", &providers.Context{
+		WorkingDirectory: "/src/app",
+		Git:              &providers.GitContext{Branch: "main", Status: status},
+		PreviousPrompts: []providers.HistoryEntry{
+			{Prompt: "how do I amend a commit", Response: "git commit --amend"},
+		},
+	})
+}
+
+func TestBuildRequestKeepsCachedPrefixStable(t *testing.T) {
+	p := &Provider{cfg: providers.Config{Model: "claude-sonnet-4", MaxTokens: 1024}}
+
+	first, err := p.buildRequest(conversationRequest("M main.go"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.buildRequest(conversationRequest("M main.go\n?? new.go"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything up to the history breakpoint must be identical for the
+	// cached history to be read back
+	prefix := func(req *request) string {
+		data, err := json.Marshal(struct {
+			System   []contentBlock
+			Messages []message
+		}{req.System, req.Messages[:len(req.Messages)-1]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if prefix(first) != prefix(second) {
+		t.Errorf("cached prefix changed with the context:\n%s\n%s", prefix(first), prefix(second))
+	}
+
+	history := first.Messages[len(first.Messages)-2].Content
+	if history[len(history)-1].CacheControl == nil {
+		t.Error("history is not marked for caching")
+	}
+
+	for _, block := range first.System {
+		if strings.Contains(block.Text, "M main.go") {
+			t.Error("the changing context is in the system prompt")
+		}
+	}
+	prompt := first.Messages[len(first.Messages)-1].Content
+	if len(prompt) != 2 || !strings.Contains(prompt[0].Text, "M main.go") || prompt[1].Text != "and how do I undo it?" {
+		t.Errorf("prompt blocks = %+v, want the context then the question", prompt)
+	}
+}
+
+func TestBuildRequestWithoutHistoryKeepsContextInSystemPrompt(t *testing.T) {
+	p := &Provider{cfg: providers.Config{Model: "claude-sonnet-4", MaxTokens: 1024}}
+
+	req := providers.NewRequest("what changed?", &providers.Context{
+		Git: &providers.GitContext{Branch: "main", Status: "M main.go"},
+	})
+	apiReq, err := p.buildRequest(req, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apiReq.Messages) != 1 || len(apiReq.Messages[0].Content) != 1 {
+		t.Fatalf("messages = %+v, want the question alone", apiReq.Messages)
+	}
+
+	var system string
+	for _, block := range apiReq.System {
+		system += block.Text
+	}
+	if !strings.Contains(system, "M main.go") {
+		t.Errorf("system prompt %q lacks the context", system)
+	}
+}
@@ -0,0 +1,214 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Server-sent event payloads of the Messages streaming API
+type streamEvent struct {
+	Type         string        `json:"type"`
+	Message      *response     `json:"message,omitempty"`
+	Index        int           `json:"index"`
+	ContentBlock *contentBlock `json:"content_block,omitempty"`
+	Delta        *streamDelta  `json:"delta,omitempty"`
+	Usage        *usage        `json:"usage,omitempty"`
+	Error        *apiError     `json:"error,omitempty"`
+}
+
+type streamDelta struct {
+	Type         string `json:"type"`
+	Text         string `json:"text"`
+	Thinking     string `json:"thinking"`
+	Signature    string `json:"signature"`
+	PartialJSON  string `json:"partial_json"`
+	StopReason   string `json:"stop_reason"`
+	StopSequence string `json:"stop_sequence"`
+}
+
+// streamState accumulates message metadata while the stream is consumed
+type streamState struct {
+	model        string
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+
+	cacheReadTokens  int
+	cacheWriteTokens int
+}
+
+// streamTurns consumes the event stream in body and emits chunks on out.
+// When the model calls tools, their results are sent back and the next
+// reply is streamed, until the model answers.
+func (p *Provider) streamTurns(ctx context.Context, req *providers.Request, apiReq *request, body io.ReadCloser, out chan<- providers.StreamResponse) {
+	defer close(out)
+
+	state := &streamState{}
+
+	for turn := 0; ; turn++ {
+		content, err := readStream(ctx, body, out, state)
+		if err != nil {
+			// A cancelled request closes the stream without an error chunk
+			if ctx.Err() == nil {
+				providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+			}
+			return
+		}
+
+		if state.stopReason != stopToolUse {
+			providers.SendChunk(ctx, out, providers.StreamResponse{
+				Done:     true,
+				Metadata: state.metadata(),
+			})
+			return
+		}
+
+		// Separate the text of consecutive replies
+		if hasText(content) && !providers.SendChunk(ctx, out, providers.StreamResponse{Text: "\n\n"}) {
+			return
+		}
+
+		if err := p.appendToolResults(ctx, req, apiReq, content, turn); err != nil {
+			if ctx.Err() == nil {
+				providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+			}
+			return
+		}
+
+		body, err = p.openStream(ctx, apiReq)
+		if err != nil {
+			if ctx.Err() == nil {
+				providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+			}
+			return
+		}
+	}
+}
+
+// readStream consumes the server-sent events of one reply from body, emits
+// its text on out and returns the reply's content blocks
+func readStream(ctx context.Context, body io.ReadCloser, out chan<- providers.StreamResponse, state *streamState) ([]contentBlock, error) {
+	defer body.Close()
+
+	reader := providers.NewSSEReader(body)
+	var content []contentBlock
+	var partialJSON []strings.Builder
+	var outputTokens int
+
+	for {
+		sse, err := reader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("stream ended before message_stop")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(sse.Data), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				state.model = event.Message.Model
+				state.inputTokens += event.Message.Usage.InputTokens
+				state.cacheReadTokens += event.Message.Usage.CacheReadInputTokens
+				state.cacheWriteTokens += event.Message.Usage.CacheCreationInputTokens
+				outputTokens = event.Message.Usage.OutputTokens
+			}
+			state.stopReason = ""
+
+		case "content_block_start":
+			for len(content) <= event.Index {
+				content = append(content, contentBlock{})
+				partialJSON = append(partialJSON, strings.Builder{})
+			}
+			if event.ContentBlock != nil {
+				content[event.Index] = *event.ContentBlock
+			}
+
+		case "content_block_delta":
+			if event.Delta == nil || event.Index >= len(content) {
+				continue
+			}
+
+			switch event.Delta.Type {
+			case "text_delta":
+				content[event.Index].Text += event.Delta.Text
+				if event.Delta.Text != "" && !providers.SendChunk(ctx, out, providers.StreamResponse{Text: event.Delta.Text}) {
+					return nil, ctx.Err()
+				}
+			case "thinking_delta":
+				content[event.Index].Thinking += event.Delta.Thinking
+				if event.Delta.Thinking != "" && !providers.SendChunk(ctx, out, providers.StreamResponse{Thinking: event.Delta.Thinking}) {
+					return nil, ctx.Err()
+				}
+			case "signature_delta":
+				content[event.Index].Signature += event.Delta.Signature
+			case "input_json_delta":
+				partialJSON[event.Index].WriteString(event.Delta.PartialJSON)
+			}
+
+		case "content_block_stop":
+			if event.Index < len(content) && content[event.Index].Type == "tool_use" {
+				input := partialJSON[event.Index].String()
+				if input == "" {
+					input = "{}"
+				}
+				content[event.Index].Input = json.RawMessage(input)
+			}
+
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				state.stopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				// Output usage is cumulative within the reply
+				outputTokens = event.Usage.OutputTokens
+			}
+
+		case "message_stop":
+			state.outputTokens += outputTokens
+			return content, nil
+
+		case "error":
+			if event.Error != nil {
+				return nil, providers.NewAPIError(ProviderName, errorTypeStatus[event.Error.Type], nil, event.Error.Type, event.Error.Message)
+			}
+			return nil, fmt.Errorf("API error: %s", sse.Data)
+
+		default:
+			// ping events carry nothing
+		}
+	}
+}
+
+// hasText reports whether a reply contains any text
+func hasText(content []contentBlock) bool {
+	for _, block := range content {
+		if block.Type == "text" && block.Text != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// metadata returns the accumulated message metadata for the final chunk
+func (s *streamState) metadata() map[string]interface{} {
+	return map[string]interface{}{
+		providers.MetadataModel:        s.model,
+		providers.MetadataStopReason:   s.stopReason,
+		providers.MetadataInputTokens:  s.inputTokens,
+		providers.MetadataOutputTokens: s.outputTokens,
+
+		providers.MetadataCacheReadTokens:  s.cacheReadTokens,
+		providers.MetadataCacheWriteTokens: s.cacheWriteTokens,
+	}
+}
@@ -0,0 +1,55 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// countRequest is the body of the token counting endpoint, which accepts the
+// fields of a messages request that contribute to its input
+type countRequest struct {
+	Model    string                     `json:"model"`
+	Messages []message                  `json:"messages"`
+	System   []contentBlock             `json:"system,omitempty"`
+	Tools    []providers.ToolDefinition `json:"tools,omitempty"`
+}
+
+type countResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// CountTokens implements the providers.TokenCounter interface using the
+// token counting endpoint
+func (p *Provider) CountTokens(ctx context.Context, req *providers.Request) (int, error) {
+	apiReq, err := p.buildRequest(req, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	jsonData, err := json.Marshal(countRequest{
+		Model:    apiReq.Model,
+		Messages: apiReq.Messages,
+		System:   apiReq.System,
+		Tools:    apiReq.Tools,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/messages/count_tokens"), bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	var countResp countResponse
+	if err := p.doRequest(httpReq, &countResp); err != nil {
+		return 0, err
+	}
+
+	return countResp.InputTokens, nil
+}
@@ -1,15 +1,187 @@
 package providers
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
 
 // Common errors
 var (
 	ErrInvalidAPIKey      = fmt.Errorf("invalid or missing API key")
 	ErrInvalidModel       = fmt.Errorf("invalid or unsupported model")
 	ErrInvalidBaseURL     = fmt.Errorf("invalid base URL")
+	ErrInvalidRequest     = fmt.Errorf("invalid request")
 	ErrProviderNotFound   = fmt.Errorf("provider not found")
 	ErrContextTooLarge    = fmt.Errorf("context exceeds maximum size")
 	ErrRateLimitExceeded  = fmt.Errorf("rate limit exceeded")
 	ErrQuotaExceeded      = fmt.Errorf("quota exceeded")
 	ErrServiceUnavailable = fmt.Errorf("service unavailable")
+	ErrContentBlocked     = fmt.Errorf("content blocked by safety filters")
 )
+
+// APIError is an error response from a provider's API. It wraps the
+// matching sentinel error, so callers can test it with errors.Is.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Type       string // provider-specific error type or code
+	Message    string
+
+	// RetryAfter is how long the provider asked us to wait, if it said
+	RetryAfter time.Duration
+
+	// Err is the sentinel error the response was classified as
+	Err error
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	// Errors delivered mid-stream have no status code
+	var details []string
+	if e.StatusCode != 0 {
+		details = append(details, strconv.Itoa(e.StatusCode))
+	}
+	if e.Type != "" {
+		details = append(details, e.Type)
+	}
+
+	fmt.Fprintf(&b, "%s API error", e.Provider)
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, " "))
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+
+	if e.RetryAfter > 0 {
+		fmt.Fprintf(&b, " (retry after %s)", e.RetryAfter)
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the sentinel error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// NewAPIError creates an APIError from a non-200 HTTP response, classifying
+// it by status code and message
+func NewAPIError(provider string, statusCode int, header http.Header, errType, message string) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Type:       errType,
+		Message:    message,
+		RetryAfter: ParseRetryAfter(header),
+		Err:        classifyAPIError(statusCode, errType, message),
+	}
+}
+
+// classifyAPIError maps an HTTP status and error message to a sentinel error
+func classifyAPIError(statusCode int, errType, message string) error {
+	text := strings.ToLower(errType + " " + message)
+
+	// Exhausted credits and billing quotas do not recover by retrying, and
+	// providers report them with a variety of status codes
+	for _, marker := range []string{"insufficient_quota", "credit balance", "billing", "quota exceeded"} {
+		if strings.Contains(text, marker) {
+			return ErrQuotaExceeded
+		}
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrInvalidAPIKey
+	case statusCode == http.StatusNotFound:
+		return ErrInvalidModel
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrContextTooLarge
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimitExceeded
+	case statusCode >= http.StatusInternalServerError:
+		// Includes Anthropic's 529 overloaded status
+		return ErrServiceUnavailable
+	case statusCode == http.StatusBadRequest:
+		for _, marker := range []string{"too long", "too many tokens", "context length", "context window", "maximum context"} {
+			if strings.Contains(text, marker) {
+				return ErrContextTooLarge
+			}
+		}
+		return ErrInvalidRequest
+	}
+
+	return nil
+}
+
+// ParseRetryAfter reads the retry-after hint from response headers, either
+// in milliseconds, in seconds or as an HTTP date
+func ParseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if n, err := strconv.ParseFloat(ms, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("retry-after")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// IsRetryable reports whether a request that failed with err may succeed
+// when sent again
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// The caller gave up, retrying would ignore that
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrRateLimitExceeded) || errors.Is(err, ErrServiceUnavailable) {
+		return true
+	}
+
+	// Transient transport failures: timeouts and dropped connections
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryAfter returns the retry-after hint carried by err, if any
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
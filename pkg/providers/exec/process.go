@@ -0,0 +1,299 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	osexec "os/exec"
+	"strings"
+	"sync"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// maxStderr is how much of the plugin's standard error is kept to explain
+// a crash
+const maxStderr = 4096
+
+// process is a running plugin and the requests waiting for its answers
+type process struct {
+	command string
+	cmd     *osexec.Cmd
+	stdin   io.WriteCloser
+	stderr  *tailBuffer
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*call
+
+	// done is closed once the plugin has exited, with the reason in err
+	done chan struct{}
+	err  error
+}
+
+// call is a request waiting for its response. Stream chunks are delivered
+// on chunks until the response arrives; gone is closed when the caller
+// stops waiting.
+type call struct {
+	result chan *incoming
+	chunks chan chunkParams
+	gone   chan struct{}
+}
+
+// startProcess spawns the plugin and starts reading its output
+func startProcess(command string, args []string) (*process, error) {
+	cmd := osexec.Command(command, args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin stdout: %w", err)
+	}
+	stderr := &tailBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", command, err)
+	}
+
+	p := &process{
+		command: command,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  stderr,
+		pending: make(map[int64]*call),
+		done:    make(chan struct{}),
+	}
+	go p.readLoop(stdout)
+
+	return p, nil
+}
+
+// readLoop dispatches the plugin's messages until it closes its output,
+// then fails the requests still waiting
+func (p *process) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg incoming
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// Stray output, such as a debug print, is not fatal
+			continue
+		}
+
+		switch {
+		case msg.Method == notificationChunk:
+			p.deliverChunk(msg.Params)
+		case msg.Method == "" && msg.ID != nil:
+			p.deliverResult(&msg)
+		}
+	}
+
+	// Output the protocol cannot carry leaves the plugin unusable
+	if scanner.Err() != nil {
+		p.kill()
+	}
+
+	waitErr := p.cmd.Wait()
+	reason := "exited"
+	if waitErr != nil {
+		reason += " (" + waitErr.Error() + ")"
+	}
+	if tail := strings.TrimSpace(p.stderr.String()); tail != "" {
+		reason += ": " + tail
+	}
+
+	p.mu.Lock()
+	p.err = fmt.Errorf("%w: plugin %s %s", providers.ErrServiceUnavailable, p.command, reason)
+	p.pending = nil
+	p.mu.Unlock()
+	close(p.done)
+}
+
+func (p *process) deliverChunk(raw json.RawMessage) {
+	var chunk chunkParams
+	if json.Unmarshal(raw, &chunk) != nil {
+		return
+	}
+
+	p.mu.Lock()
+	c := p.pending[chunk.ID]
+	p.mu.Unlock()
+	if c == nil || c.chunks == nil {
+		return
+	}
+
+	// Chunks of abandoned requests are dropped rather than blocking the
+	// responses of the others
+	select {
+	case c.chunks <- chunk:
+	case <-c.gone:
+	}
+}
+
+func (p *process) deliverResult(msg *incoming) {
+	p.mu.Lock()
+	c := p.pending[*msg.ID]
+	delete(p.pending, *msg.ID)
+	p.mu.Unlock()
+
+	if c != nil {
+		c.result <- msg
+	}
+}
+
+// start sends a request and returns the call waiting for its response.
+// Chunks are only delivered when stream is set.
+func (p *process) start(method string, params interface{}, stream bool) (int64, *call, error) {
+	c := &call{result: make(chan *incoming, 1), gone: make(chan struct{})}
+	if stream {
+		c.chunks = make(chan chunkParams, 64)
+	}
+
+	p.mu.Lock()
+	if p.pending == nil {
+		err := p.err
+		p.mu.Unlock()
+		return 0, nil, err
+	}
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = c
+	p.mu.Unlock()
+
+	if err := p.write(message{JSONRPC: jsonrpcVersion, ID: &id, Method: method, Params: params}); err != nil {
+		p.forget(id)
+		return 0, nil, err
+	}
+
+	return id, c, nil
+}
+
+// forget stops waiting for the response to request id
+func (p *process) forget(id int64) {
+	p.mu.Lock()
+	if c, ok := p.pending[id]; ok {
+		delete(p.pending, id)
+		close(c.gone)
+	}
+	p.mu.Unlock()
+}
+
+// cancel tells the plugin that the caller gave up on request id
+func (p *process) cancel(id int64) {
+	p.forget(id)
+	p.write(message{JSONRPC: jsonrpcVersion, Method: notificationCancel, Params: cancelParams{ID: id}})
+}
+
+func (p *process) write(msg message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		select {
+		case <-p.done:
+			return p.err
+		default:
+			return fmt.Errorf("%w: failed to write to plugin %s: %v", providers.ErrServiceUnavailable, p.command, err)
+		}
+	}
+	return nil
+}
+
+// exited reports whether the plugin has exited
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill stops a plugin that no longer answers
+func (p *process) kill() {
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+}
+
+// close asks the plugin to exit by closing its input
+func (p *process) close() {
+	p.stdin.Close()
+}
+
+// wait waits for the response to a call started with start, decoding its
+// result into result
+func (p *process) wait(ctx context.Context, id int64, c *call, result interface{}) error {
+	select {
+	case msg := <-c.result:
+		return decodeResult(msg, result)
+	case <-p.done:
+		// The response may have arrived just before the plugin exited
+		select {
+		case msg := <-c.result:
+			return decodeResult(msg, result)
+		default:
+			return p.err
+		}
+	case <-ctx.Done():
+		p.cancel(id)
+		return ctx.Err()
+	}
+}
+
+// decodeResult converts a response into result or its error
+func decodeResult(msg *incoming, result interface{}) error {
+	if msg.Error != nil {
+		return msg.Error.err()
+	}
+	if result == nil || len(msg.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		return fmt.Errorf("failed to decode plugin response: %w", err)
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, data...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(data), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,154 @@
+// Package exec implements a provider backed by an external executable, so
+// that other LLM clients can be plugged in without changing this project.
+//
+// The plugin is spawned once per process and speaks JSON-RPC 2.0 over its
+// standard input and output, one message per line. Its standard error is
+// kept to explain crashes. The methods called by how are:
+//
+//	initialize  {"protocol_version", "config"}    -> {"name", "version", "capabilities"}
+//	validate    {}                                -> {}
+//	models      {}                                -> {"models": [...]}
+//	send        chatParams                        -> chatResult
+//	stream      chatParams                        -> chatResult, after "stream.chunk" notifications
+//	ping        {}                                -> {}, optional
+//
+// While answering a stream request the plugin sends notifications of the form
+// {"method": "stream.chunk", "params": {"id": <request id>, "text": "..."}}.
+// When the caller gives up, how sends a "cancel" notification with the id of
+// the abandoned request. Errors may carry {"status", "type", "retry_after"}
+// in their data, with status being the equivalent HTTP status code, so that
+// they are retried and fallen back from like the errors of built-in providers.
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// ProtocolVersion is the version of the plugin protocol sent on initialize
+const ProtocolVersion = 1
+
+// Methods and notifications of the plugin protocol
+const (
+	methodInitialize = "initialize"
+	methodValidate   = "validate"
+	methodModels     = "models"
+	methodSend       = "send"
+	methodStream     = "stream"
+	methodPing       = "ping"
+
+	notificationChunk  = "stream.chunk"
+	notificationCancel = "cancel"
+)
+
+const jsonrpcVersion = "2.0"
+
+// codeMethodNotFound is the JSON-RPC error code of an unknown method
+const codeMethodNotFound = -32601
+
+// message is any JSON-RPC message: a request or notification when Method is
+// set, otherwise a response to the request with the same ID
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// incoming is a message read from the plugin, with its params left raw
+type incoming struct {
+	ID     *int64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    *rpcErrorData `json:"data,omitempty"`
+}
+
+type rpcErrorData struct {
+	Status     int     `json:"status,omitempty"`
+	Type       string  `json:"type,omitempty"`
+	RetryAfter float64 `json:"retry_after,omitempty"` // seconds
+}
+
+// err converts an error response into an APIError, classified by the
+// status and type the plugin reported
+func (e *rpcError) err() error {
+	var data rpcErrorData
+	if e.Data != nil {
+		data = *e.Data
+	}
+
+	apiErr := providers.NewAPIError(ProviderName, data.Status, nil, data.Type, e.Message)
+	apiErr.RetryAfter = time.Duration(data.RetryAfter * float64(time.Second))
+	if apiErr.Type == "" && data.Status == 0 {
+		apiErr.Type = fmt.Sprint(e.Code)
+	}
+
+	return apiErr
+}
+
+// pluginConfig is the provider configuration passed on initialize
+type pluginConfig struct {
+	Model         string            `json:"model,omitempty"`
+	APIKey        string            `json:"api_key,omitempty"`
+	BaseURL       string            `json:"base_url,omitempty"`
+	MaxTokens     int               `json:"max_tokens,omitempty"`
+	CustomHeaders map[string]string `json:"custom_headers,omitempty"`
+}
+
+type initializeParams struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	Config          pluginConfig `json:"config"`
+}
+
+type initializeResult struct {
+	Name         string                  `json:"name,omitempty"`
+	Version      string                  `json:"version,omitempty"`
+	Capabilities *providers.Capabilities `json:"capabilities,omitempty"`
+}
+
+type modelsResult struct {
+	Models []string `json:"models"`
+}
+
+// chatParams is a prompt with the system prompt already built, so plugins
+// do not need to know about the gathered context
+type chatParams struct {
+	Model         string              `json:"model"`
+	System        string              `json:"system"`
+	Messages      []providers.Message `json:"messages"`
+	MaxTokens     int                 `json:"max_tokens,omitempty"`
+	Temperature   *float32            `json:"temperature,omitempty"`
+	TopP          *float32            `json:"top_p,omitempty"`
+	StopSequences []string            `json:"stop_sequences,omitempty"`
+}
+
+type chatResult struct {
+	Text         string `json:"text,omitempty"`
+	Thinking     string `json:"thinking,omitempty"`
+	Model        string `json:"model,omitempty"`
+	StopReason   string `json:"stop_reason,omitempty"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
+}
+
+type chunkParams struct {
+	ID       int64  `json:"id"`
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+}
+
+type cancelParams struct {
+	ID int64 `json:"id"`
+}
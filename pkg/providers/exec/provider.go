@@ -0,0 +1,403 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	osexec "os/exec"
+	"sync"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider implements the providers.Provider interface by forwarding calls
+// to a plugin executable
+type Provider struct {
+	cfg providers.Config
+
+	// mu guards the running plugin, which is started on first use and
+	// restarted after a crash
+	mu   sync.Mutex
+	proc *process
+	info *initializeResult
+}
+
+const (
+	// ProviderName is the plugin provider name
+	ProviderName = "exec"
+	displayName  = "Plugin"
+	description  = "An external executable speaking JSON-RPC over stdin and stdout."
+
+	// defaultTimeout is how long a plugin may take to answer a request, or
+	// to send the next chunk of a stream
+	defaultTimeout = 2 * time.Minute
+
+	// maxTemperature is the highest sampling temperature passed on; the
+	// plugin may reject lower ones
+	maxTemperature = 2
+)
+
+// NewProvider creates a new plugin provider instance. The plugin is not
+// started until it is first needed.
+func NewProvider(cfg providers.Config) (providers.Provider, error) {
+	return &Provider{cfg: cfg}, nil
+}
+
+// SendPrompt implements the providers.Provider interface
+func (p *Provider) SendPrompt(prompt string, promptContext *providers.Context) (*providers.Response, error) {
+	return p.Send(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// SendPromptStream implements streaming for the providers.Provider interface
+func (p *Provider) SendPromptStream(prompt string, promptContext *providers.Context) (<-chan providers.StreamResponse, error) {
+	return p.Stream(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	startTime := time.Now()
+
+	params, err := p.buildParams(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Send the request, retrying transient failures. A crashed plugin is
+	// restarted by the next attempt.
+	var result chatResult
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		return p.invoke(ctx, methodSend, params, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	model := result.Model
+	if model == "" {
+		model = p.cfg.Model
+	}
+
+	return &providers.Response{
+		Text:         result.Text,
+		Thinking:     result.Thinking,
+		Model:        model,
+		Provider:     ProviderName,
+		TokensUsed:   result.InputTokens + result.OutputTokens,
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		ResponseTime: time.Since(startTime),
+	}, nil
+}
+
+// Stream implements the providers.ProviderV2 interface
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	params, err := p.buildParams(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Start the stream, retrying when the plugin cannot be started or
+	// reached. Errors after that are reported on the channel.
+	var (
+		proc *process
+		id   int64
+		c    *call
+	)
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		proc, err = p.plugin(ctx)
+		if err != nil {
+			return err
+		}
+		id, c, err = proc.start(methodStream, params, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse)
+	go p.readStream(ctx, proc, id, c, chunks)
+
+	return chunks, nil
+}
+
+// readStream forwards the chunks of stream request id until its response
+// arrives, the plugin exits or stays silent for longer than the timeout
+func (p *Provider) readStream(ctx context.Context, proc *process, id int64, c *call, out chan<- providers.StreamResponse) {
+	defer close(out)
+
+	timeout := p.timeout()
+	idle := time.NewTimer(timeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case chunk := <-c.chunks:
+			if !sendChunk(ctx, out, chunk) {
+				proc.cancel(id)
+				return
+			}
+
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(timeout)
+
+		case msg := <-c.result:
+			finishStream(ctx, c, msg, out)
+			return
+
+		case <-proc.done:
+			// The response may have arrived just before the plugin exited
+			select {
+			case msg := <-c.result:
+				finishStream(ctx, c, msg, out)
+			default:
+				providers.SendChunk(ctx, out, providers.StreamResponse{Error: proc.err})
+			}
+			return
+
+		case <-idle.C:
+			proc.kill()
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: p.timeoutError(methodStream)})
+			return
+
+		case <-ctx.Done():
+			proc.cancel(id)
+			return
+		}
+	}
+}
+
+// finishStream delivers the chunks still buffered and then the final chunk
+// with the response's metadata
+func finishStream(ctx context.Context, c *call, msg *incoming, out chan<- providers.StreamResponse) {
+	for drained := false; !drained; {
+		select {
+		case chunk := <-c.chunks:
+			if !sendChunk(ctx, out, chunk) {
+				return
+			}
+		default:
+			drained = true
+		}
+	}
+
+	var result chatResult
+	if err := decodeResult(msg, &result); err != nil {
+		providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+		return
+	}
+
+	providers.SendChunk(ctx, out, providers.StreamResponse{
+		Done: true,
+		Metadata: map[string]interface{}{
+			providers.MetadataModel:        result.Model,
+			providers.MetadataStopReason:   result.StopReason,
+			providers.MetadataInputTokens:  result.InputTokens,
+			providers.MetadataOutputTokens: result.OutputTokens,
+		},
+	})
+}
+
+func sendChunk(ctx context.Context, out chan<- providers.StreamResponse, chunk chunkParams) bool {
+	return providers.SendChunk(ctx, out, providers.StreamResponse{Text: chunk.Text, Thinking: chunk.Thinking})
+}
+
+// ValidateConfig implements the providers.Provider interface. The plugin is
+// not started; it checks the configuration itself when it starts.
+func (p *Provider) ValidateConfig() error {
+	if p.cfg.Command == "" {
+		return fmt.Errorf("command is required for the %s provider", ProviderName)
+	}
+
+	if p.cfg.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", p.cfg.MaxTokens)
+	}
+
+	if p.cfg.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %s", p.cfg.Timeout)
+	}
+
+	if err := p.cfg.ValidateSampling(maxTemperature); err != nil {
+		return err
+	}
+
+	if _, err := osexec.LookPath(p.cfg.Command); err != nil {
+		return fmt.Errorf("plugin command not found: %w", err)
+	}
+
+	return nil
+}
+
+// GetInfo implements the providers.Provider interface
+func (p *Provider) GetInfo() providers.ProviderInfo {
+	info := providers.ProviderInfo{
+		Name:        displayName,
+		Type:        ProviderName,
+		Model:       p.cfg.Model,
+		Description: description,
+	}
+
+	// Plugins that have been started may name themselves
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.info != nil {
+		if p.info.Name != "" {
+			info.Name = p.info.Name
+		}
+		info.Version = p.info.Version
+	}
+
+	return info
+}
+
+// GetCapabilities implements the providers.Provider interface, using the
+// capabilities the plugin reported when it was started
+func (p *Provider) GetCapabilities() providers.Capabilities {
+	caps := providers.Capabilities{
+		Streaming:          true,
+		ConversationMemory: true,
+		MaxContextSize:     8192,
+		MaxTokens:          4096,
+	}
+
+	p.mu.Lock()
+	if p.info != nil && p.info.Capabilities != nil {
+		caps = *p.info.Capabilities
+	}
+	p.mu.Unlock()
+
+	// Tools are not part of the protocol
+	caps.FunctionCalling = false
+
+	return p.cfg.Capabilities.Apply(caps)
+}
+
+// GetModels implements the providers.Provider interface
+func (p *Provider) GetModels() ([]string, error) {
+	var result modelsResult
+	if err := p.invoke(context.Background(), methodModels, struct{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result.Models, nil
+}
+
+// Ping implements the providers.Pinger interface. Plugins that do not
+// implement the ping method are only checked to be answering.
+func (p *Provider) Ping(ctx context.Context) error {
+	err := p.invoke(ctx, methodPing, struct{}{}, nil)
+
+	var apiErr *providers.APIError
+	if errors.As(err, &apiErr) && apiErr.Type == fmt.Sprint(codeMethodNotFound) {
+		return nil
+	}
+	return err
+}
+
+// invoke calls a method of the plugin, starting it if needed
+func (p *Provider) invoke(ctx context.Context, method string, params, result interface{}) error {
+	proc, err := p.plugin(ctx)
+	if err != nil {
+		return err
+	}
+	return p.call(ctx, proc, method, params, result)
+}
+
+// call calls a method of a running plugin and waits for its response. A
+// plugin that does not answer within the timeout is killed.
+func (p *Provider) call(ctx context.Context, proc *process, method string, params, result interface{}) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	id, c, err := proc.start(method, params, false)
+	if err != nil {
+		return err
+	}
+
+	err = proc.wait(timeoutCtx, id, c, result)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		proc.kill()
+		return p.timeoutError(method)
+	}
+	return err
+}
+
+// plugin returns the running plugin, starting, initializing and validating
+// it when it has not been started yet or has exited
+func (p *Provider) plugin(ctx context.Context) (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc != nil && !p.proc.exited() {
+		return p.proc, nil
+	}
+
+	proc, err := startProcess(p.cfg.Command, p.cfg.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	var info initializeResult
+	err = p.call(ctx, proc, methodInitialize, initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Config: pluginConfig{
+			Model:         p.cfg.Model,
+			APIKey:        p.cfg.APIKey,
+			BaseURL:       p.cfg.BaseURL,
+			MaxTokens:     p.cfg.MaxTokens,
+			CustomHeaders: p.cfg.CustomHeaders,
+		},
+	}, &info)
+	if err != nil {
+		proc.kill()
+		return nil, fmt.Errorf("failed to initialize plugin %s: %w", p.cfg.Command, err)
+	}
+
+	if err := p.call(ctx, proc, methodValidate, struct{}{}, nil); err != nil {
+		proc.kill()
+		return nil, fmt.Errorf("plugin %s rejected its configuration: %w", p.cfg.Command, err)
+	}
+
+	if p.proc != nil {
+		p.proc.close()
+	}
+	p.proc, p.info = proc, &info
+
+	return proc, nil
+}
+
+// buildParams creates the parameters of a send or stream request
+func (p *Provider) buildParams(req *providers.Request) (*chatParams, error) {
+	systemPrompt, err := req.SystemPrompt(p.cfg.SystemPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chatParams{
+		Model:         p.cfg.Model,
+		System:        systemPrompt,
+		Messages:      req.Messages,
+		MaxTokens:     req.MaxTokens(p.cfg.MaxTokens),
+		Temperature:   req.Temperature(p.cfg.Temperature),
+		TopP:          req.TopP(p.cfg.TopP),
+		StopSequences: req.Options.StopSequences,
+	}, nil
+}
+
+func (p *Provider) timeout() time.Duration {
+	if p.cfg.Timeout > 0 {
+		return p.cfg.Timeout
+	}
+	return defaultTimeout
+}
+
+// timeoutError reports a plugin that stopped answering, which is treated
+// like an unavailable service so that it is retried or fallen back from
+func (p *Provider) timeoutError(method string) error {
+	return fmt.Errorf("%w: plugin %s did not answer %s within %s", providers.ErrServiceUnavailable, p.cfg.Command, method, p.timeout())
+}
@@ -0,0 +1,327 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// The test binary doubles as the plugin when this variable is set
+const pluginEnv = "HOW_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(pluginEnv) == "1" {
+		runTestPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestPlugin answers requests the way a plugin would. Its configured
+// model and the last message of a prompt choose how it behaves:
+//
+//	model "reject"      fails validation
+//	model "pinger"      implements ping
+//	prompt "crash"      exits with a message on stderr
+//	prompt "hang"       never answers
+//	prompt "flaky"      fails as unavailable once, then answers
+//	prompt "forbidden"  fails with a 403 status
+//
+// Anything else is echoed back, word by word when streamed.
+func runTestPlugin() {
+	out := json.NewEncoder(os.Stdout)
+	reply := func(id *int64, result interface{}) {
+		data, _ := json.Marshal(result)
+		out.Encode(message{JSONRPC: jsonrpcVersion, ID: id, Result: data})
+	}
+	fail := func(id *int64, rpcErr *rpcError) {
+		out.Encode(message{JSONRPC: jsonrpcVersion, ID: id, Error: rpcErr})
+	}
+
+	var (
+		cfg     pluginConfig
+		flakies int
+	)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg incoming
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil || msg.ID == nil {
+			continue
+		}
+
+		switch msg.Method {
+		case methodInitialize:
+			var params initializeParams
+			json.Unmarshal(msg.Params, &params)
+			if params.ProtocolVersion != ProtocolVersion {
+				fail(msg.ID, &rpcError{Code: -32602, Message: "unsupported protocol version"})
+				continue
+			}
+			cfg = params.Config
+
+			// Output that is not JSON-RPC is skipped
+			fmt.Println("starting up")
+			reply(msg.ID, initializeResult{
+				Name:         "Echo",
+				Version:      "1.0.0",
+				Capabilities: &providers.Capabilities{Streaming: true, MaxContextSize: 1000, MaxTokens: 100},
+			})
+
+		case methodValidate:
+			if cfg.Model == "reject" {
+				fail(msg.ID, &rpcError{Code: -32000, Message: "unknown model", Data: &rpcErrorData{Status: 404}})
+				continue
+			}
+			reply(msg.ID, struct{}{})
+
+		case methodModels:
+			reply(msg.ID, modelsResult{Models: []string{"echo-small", "echo-large"}})
+
+		case methodPing:
+			if cfg.Model != "pinger" {
+				fail(msg.ID, &rpcError{Code: codeMethodNotFound, Message: "method not found"})
+				continue
+			}
+			reply(msg.ID, struct{}{})
+
+		case methodSend, methodStream:
+			var params chatParams
+			json.Unmarshal(msg.Params, &params)
+			prompt := params.Messages[len(params.Messages)-1].Content
+
+			switch prompt {
+			case "crash":
+				fmt.Fprintln(os.Stderr, "boom")
+				os.Exit(3)
+			case "hang":
+				continue
+			case "flaky":
+				flakies++
+				if flakies == 1 {
+					fail(msg.ID, &rpcError{Code: -32000, Message: "overloaded", Data: &rpcErrorData{Status: 503, RetryAfter: 0.001}})
+					continue
+				}
+			case "forbidden":
+				fail(msg.ID, &rpcError{Code: -32000, Message: "bad key", Data: &rpcErrorData{Status: 403, Type: "authentication_error"}})
+				continue
+			}
+
+			text := "echo: " + prompt
+			if msg.Method == methodStream {
+				for _, word := range strings.SplitAfter(text, " ") {
+					out.Encode(message{JSONRPC: jsonrpcVersion, Method: notificationChunk, Params: chunkParams{ID: *msg.ID, Text: word}})
+				}
+				text = ""
+			}
+			reply(msg.ID, chatResult{Text: text, Model: cfg.Model, StopReason: "end_turn", InputTokens: 3, OutputTokens: 2})
+
+		default:
+			fail(msg.ID, &rpcError{Code: codeMethodNotFound, Message: "method not found"})
+		}
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// newTestProvider returns a provider running the test binary as its plugin
+func newTestProvider(t *testing.T, model string) *Provider {
+	t.Helper()
+	t.Setenv(pluginEnv, "1")
+
+	provider, err := NewProvider(providers.Config{
+		Type:    ProviderName,
+		Command: os.Args[0],
+		Model:   model,
+		Timeout: 2 * time.Second,
+		Retry:   &providers.RetryPolicy{MaxRetries: intPtr(0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := provider.(*Provider)
+	t.Cleanup(func() {
+		p.mu.Lock()
+		if p.proc != nil {
+			p.proc.kill()
+		}
+		p.mu.Unlock()
+	})
+	return p
+}
+
+// running returns the plugin currently started, if any
+func (p *Provider) running() *process {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.proc
+}
+
+func TestSend(t *testing.T) {
+	p := newTestProvider(t, "echo-small")
+
+	resp, err := p.Send(context.Background(), providers.NewRequest("hello there", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "echo: hello there" || resp.Model != "echo-small" || resp.Provider != ProviderName {
+		t.Errorf("response = %+v, want the prompt echoed", resp)
+	}
+	if resp.InputTokens != 3 || resp.OutputTokens != 2 || resp.TokensUsed != 5 {
+		t.Errorf("tokens = %d in, %d out, %d total; want 3, 2, 5", resp.InputTokens, resp.OutputTokens, resp.TokensUsed)
+	}
+
+	// The plugin introduced itself on initialize
+	if info := p.GetInfo(); info.Name != "Echo" || info.Version != "1.0.0" {
+		t.Errorf("info = %+v, want the plugin's name and version", info)
+	}
+	if caps := p.GetCapabilities(); caps.MaxContextSize != 1000 || caps.MaxTokens != 100 {
+		t.Errorf("capabilities = %+v, want those the plugin reported", caps)
+	}
+
+	models, err := p.GetModels()
+	if err != nil || len(models) != 2 {
+		t.Errorf("GetModels = %v, %v", models, err)
+	}
+
+	// Later requests reuse the running plugin
+	proc := p.running()
+	if _, err := p.Send(context.Background(), providers.NewRequest("again", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if p.running() != proc {
+		t.Error("the plugin was restarted between requests")
+	}
+}
+
+func TestStream(t *testing.T) {
+	p := newTestProvider(t, "echo-small")
+
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("one two three", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		text string
+		last providers.StreamResponse
+	)
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		text += chunk.Text
+		last = chunk
+	}
+
+	if text != "echo: one two three" {
+		t.Errorf("streamed %q, want the prompt echoed", text)
+	}
+	if !last.Done || last.Metadata[providers.MetadataStopReason] != "end_turn" || last.Metadata[providers.MetadataOutputTokens] != 2 {
+		t.Errorf("final chunk = %+v, want the response's metadata", last)
+	}
+}
+
+func TestValidationFailureStopsPlugin(t *testing.T) {
+	p := newTestProvider(t, "reject")
+
+	_, err := p.Send(context.Background(), providers.NewRequest("hello", nil))
+	if !errors.Is(err, providers.ErrInvalidModel) || !strings.Contains(err.Error(), "rejected its configuration") {
+		t.Fatalf("Send = %v, want the configuration rejected", err)
+	}
+	if p.running() != nil {
+		t.Error("a plugin that failed validation was kept")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	p := newTestProvider(t, "echo-small")
+
+	_, err := p.Send(context.Background(), providers.NewRequest("forbidden", nil))
+	var apiErr *providers.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, providers.ErrInvalidAPIKey) {
+		t.Fatalf("Send = %v, want an invalid API key error", err)
+	}
+	if apiErr.StatusCode != 403 || apiErr.Type != "authentication_error" || apiErr.Provider != ProviderName {
+		t.Errorf("error = %+v, want the status and type the plugin reported", apiErr)
+	}
+
+	// Unavailable errors are retried, waiting as long as the plugin asked
+	p.cfg.Retry = nil
+	resp, err := p.Send(context.Background(), providers.NewRequest("flaky", nil))
+	if err != nil {
+		t.Fatalf("Send = %v, want the retry to succeed", err)
+	}
+	if resp.Text != "echo: flaky" {
+		t.Errorf("response = %q", resp.Text)
+	}
+}
+
+func TestCrashRestartsPlugin(t *testing.T) {
+	p := newTestProvider(t, "echo-small")
+
+	_, err := p.Send(context.Background(), providers.NewRequest("crash", nil))
+	if !errors.Is(err, providers.ErrServiceUnavailable) || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Send = %v, want the plugin's exit explained", err)
+	}
+	crashed := p.running()
+
+	resp, err := p.Send(context.Background(), providers.NewRequest("hello", nil))
+	if err != nil {
+		t.Fatalf("Send after a crash = %v", err)
+	}
+	if resp.Text != "echo: hello" || p.running() == crashed {
+		t.Errorf("response = %q, want it from a restarted plugin", resp.Text)
+	}
+}
+
+func TestTimeoutKillsPlugin(t *testing.T) {
+	p := newTestProvider(t, "echo-small")
+	p.cfg.Timeout = 200 * time.Millisecond
+
+	_, err := p.Send(context.Background(), providers.NewRequest("hang", nil))
+	if !errors.Is(err, providers.ErrServiceUnavailable) || !strings.Contains(err.Error(), "did not answer send") {
+		t.Fatalf("Send = %v, want a timeout", err)
+	}
+
+	select {
+	case <-p.running().done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the plugin that stopped answering was not killed")
+	}
+
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("hang", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := <-chunks
+	if !errors.Is(chunk.Error, providers.ErrServiceUnavailable) {
+		t.Errorf("stream chunk = %+v, want a timeout", chunk)
+	}
+}
+
+func TestPing(t *testing.T) {
+	// Plugins without a ping method are healthy when they answer
+	for _, model := range []string{"echo-small", "pinger"} {
+		p := newTestProvider(t, model)
+		if err := p.Ping(context.Background()); err != nil {
+			t.Errorf("Ping of %s = %v", model, err)
+		}
+	}
+
+	p := newTestProvider(t, "reject")
+	if err := p.Ping(context.Background()); err == nil {
+		t.Error("Ping of a plugin that fails validation succeeded")
+	}
+}
@@ -0,0 +1,138 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+type apiError struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Status  string        `json:"status"`
+	Details []errorDetail `json:"details"`
+}
+
+type errorDetail struct {
+	Type       string `json:"@type"`
+	Reason     string `json:"reason"`
+	RetryDelay string `json:"retryDelay"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// Finish and block reasons that mean the safety filters withheld the answer
+var blockedReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// parseErrorResponse converts a non-200 API response into a typed error.
+// Gemini's error status and details refine the classification by HTTP
+// status, and a RetryInfo detail supplies the retry delay.
+func parseErrorResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var errorResp errorResponse
+	if json.Unmarshal(body, &errorResp) != nil || errorResp.Error.Message == "" {
+		return providers.NewAPIError(ProviderName, resp.StatusCode, resp.Header, "", string(body))
+	}
+
+	return newAPIError(resp.StatusCode, resp.Header, errorResp.Error)
+}
+
+// newAPIError converts an error reported by the API, in a response or in
+// the middle of a stream, into a typed error
+func newAPIError(statusCode int, header http.Header, apiErr apiError) *providers.APIError {
+	err := providers.NewAPIError(ProviderName, statusCode, header, apiErr.Status, apiErr.Message)
+	if sentinel := classifyError(statusCode, apiErr); sentinel != nil {
+		err.Err = sentinel
+	}
+
+	for _, detail := range apiErr.Details {
+		if detail.RetryDelay == "" {
+			continue
+		}
+		if delay, parseErr := time.ParseDuration(detail.RetryDelay); parseErr == nil && delay > 0 {
+			err.RetryAfter = delay
+		}
+	}
+
+	return err
+}
+
+// classifyError maps a Gemini API error onto a sentinel error
+func classifyError(statusCode int, apiErr apiError) error {
+	for _, detail := range apiErr.Details {
+		if detail.Reason == "API_KEY_INVALID" {
+			return providers.ErrInvalidAPIKey
+		}
+	}
+
+	message := strings.ToLower(apiErr.Message)
+
+	switch {
+	case statusCode == http.StatusUnauthorized || apiErr.Status == "UNAUTHENTICATED" || apiErr.Status == "PERMISSION_DENIED":
+		return providers.ErrInvalidAPIKey
+	case statusCode == http.StatusNotFound:
+		return providers.ErrInvalidModel
+	case statusCode == http.StatusTooManyRequests || apiErr.Status == "RESOURCE_EXHAUSTED":
+		// Daily and billing quotas do not recover by waiting a minute
+		if strings.Contains(message, "per day") || strings.Contains(message, "billing") {
+			return providers.ErrQuotaExceeded
+		}
+		return providers.ErrRateLimitExceeded
+	case statusCode == http.StatusServiceUnavailable || statusCode == http.StatusInternalServerError || apiErr.Status == "UNAVAILABLE":
+		return providers.ErrServiceUnavailable
+	case statusCode == http.StatusBadRequest && strings.Contains(message, "token"):
+		if strings.Contains(message, "exceeds") || strings.Contains(message, "too long") {
+			return providers.ErrContextTooLarge
+		}
+	}
+
+	return nil
+}
+
+// checkBlocked returns ErrContentBlocked when the prompt or the answer was
+// withheld by Gemini's safety filters
+func checkBlocked(resp *response) error {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return fmt.Errorf("%w: prompt blocked (%s)%s", providers.ErrContentBlocked,
+			resp.PromptFeedback.BlockReason, formatRatings(resp.PromptFeedback.SafetyRatings))
+	}
+
+	if len(resp.Candidates) > 0 {
+		c := resp.Candidates[0]
+		if blockedReasons[c.FinishReason] {
+			return fmt.Errorf("%w: response blocked (%s)%s", providers.ErrContentBlocked,
+				c.FinishReason, formatRatings(c.SafetyRatings))
+		}
+	}
+
+	return nil
+}
+
+// formatRatings lists the safety categories that triggered a block
+func formatRatings(ratings []safetyRating) string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked || rating.Probability == "HIGH" || rating.Probability == "MEDIUM" {
+			categories = append(categories, strings.TrimPrefix(rating.Category, "HARM_CATEGORY_"))
+		}
+	}
+
+	if len(categories) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(categories, ", ")
+}
@@ -0,0 +1,464 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider implements the providers.Provider interface for Google's Gemini API
+type Provider struct {
+	httpClient *http.Client
+	baseURL    string
+	cfg        providers.Config
+}
+
+// API request/response structures
+type request struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *inlineData `json:"inlineData,omitempty"`
+}
+
+type inlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float32 `json:"temperature,omitempty"`
+	TopP            *float32 `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type response struct {
+	Candidates     []candidate     `json:"candidates"`
+	PromptFeedback *promptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *usageMetadata  `json:"usageMetadata,omitempty"`
+	ModelVersion   string          `json:"modelVersion"`
+}
+
+type candidate struct {
+	Content       content        `json:"content"`
+	FinishReason  string         `json:"finishReason"`
+	SafetyRatings []safetyRating `json:"safetyRatings"`
+}
+
+type promptFeedback struct {
+	BlockReason   string         `json:"blockReason"`
+	SafetyRatings []safetyRating `json:"safetyRatings"`
+}
+
+type safetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// outputTokens returns the tokens billed as output, which include those of
+// the model's thinking
+func (u *usageMetadata) outputTokens() int {
+	return u.CandidatesTokenCount + u.ThoughtsTokenCount
+}
+
+// modelsResponse represents the response from the models API endpoint
+type modelsResponse struct {
+	Models        []model `json:"models"`
+	NextPageToken string  `json:"nextPageToken"`
+}
+
+type model struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+const (
+	// ProviderName is google gemini provider name
+	ProviderName = "gemini"
+	baseURL      = "https://generativelanguage.googleapis.com/v1beta"
+	displayName  = "Google Gemini"
+	description  = "Google's Gemini models through the Generative Language API."
+
+	// maxTemperature is the highest sampling temperature the API accepts
+	maxTemperature = 2
+
+	// defaultTimeout limits a request unless a timeout is configured
+	defaultTimeout = 60 * time.Second
+
+	// maxImageSize is the largest image sent inline, which is also the
+	// limit of the whole request
+	maxImageSize = 20 * 1024 * 1024
+)
+
+// NewProvider creates a new Gemini provider instance
+func NewProvider(cfg providers.Config) (providers.Provider, error) {
+	apiURL := baseURL
+	if cfg.BaseURL != "" {
+		apiURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
+	httpClient, err := cfg.HTTPClient(defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		httpClient: httpClient,
+		cfg:        cfg,
+		baseURL:    apiURL,
+	}, nil
+}
+
+// SendPrompt implements the providers.Provider interface
+func (p *Provider) SendPrompt(prompt string, promptContext *providers.Context) (*providers.Response, error) {
+	return p.Send(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// SendPromptStream implements streaming for the providers.Provider interface
+func (p *Provider) SendPromptStream(prompt string, promptContext *providers.Context) (<-chan providers.StreamResponse, error) {
+	return p.Stream(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	startTime := time.Now()
+
+	// Build the request
+	apiReq, err := p.buildRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Create HTTP request
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Send the request, retrying transient failures
+	var apiResp response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", p.modelURL("generateContent"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		return p.doRequest(httpReq, &apiResp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBlocked(&apiResp); err != nil {
+		return nil, err
+	}
+
+	response := &providers.Response{
+		Text:         apiResp.text(),
+		Model:        p.responseModel(&apiResp),
+		Provider:     ProviderName,
+		ResponseTime: time.Since(startTime),
+	}
+	if apiResp.UsageMetadata != nil {
+		response.TokensUsed = apiResp.UsageMetadata.TotalTokenCount
+		response.InputTokens = apiResp.UsageMetadata.PromptTokenCount
+		response.OutputTokens = apiResp.UsageMetadata.outputTokens()
+	}
+
+	return response, nil
+}
+
+// Stream implements the providers.ProviderV2 interface
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	// Build the request
+	apiReq, err := p.buildRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Create HTTP request
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Open the stream, retrying transient failures. Errors after the
+	// first event are reported on the channel and not retried.
+	var resp *http.Response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", p.modelURL("streamGenerateContent")+"?alt=sse", bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		p.setHeaders(httpReq)
+		httpReq.Header.Set("Accept", "text/event-stream")
+
+		resp, err = providers.StreamClient(p.httpClient).Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return parseErrorResponse(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse)
+	go p.readStream(ctx, resp.Body, chunks)
+
+	return chunks, nil
+}
+
+// ValidateConfig implements the providers.Provider interface
+func (p *Provider) ValidateConfig() error {
+	if p.cfg.APIKey == "" {
+		return providers.ErrInvalidAPIKey
+	}
+
+	if p.cfg.Model == "" {
+		return providers.ErrInvalidModel
+	}
+
+	if err := p.cfg.ValidateMaxTokens(); err != nil {
+		return err
+	}
+
+	return p.cfg.ValidateSampling(maxTemperature)
+}
+
+// GetInfo implements the providers.Provider interface
+func (p *Provider) GetInfo() providers.ProviderInfo {
+	return providers.ProviderInfo{
+		Name:        displayName,
+		Type:        ProviderName,
+		Model:       p.cfg.Model,
+		Description: description,
+	}
+}
+
+// GetCapabilities implements the providers.Provider interface. Limits come
+// from the model registry, with defaults for unknown models.
+func (p *Provider) GetCapabilities() providers.Capabilities {
+	return p.cfg.Capabilities.Apply(providers.ModelCapabilities(p.cfg.Model, providers.Capabilities{
+		Streaming:          true,
+		FunctionCalling:    false,
+		CodeExecution:      false,
+		ImageAnalysis:      true,
+		ConversationMemory: true,
+		MaxContextSize:     1048576,
+		MaxTokens:          8192,
+		MaxImageSize:       maxImageSize,
+	}))
+}
+
+// GetModels implements the providers.Provider interface
+func (p *Provider) GetModels() ([]string, error) {
+	var models []string
+	pageToken := ""
+
+	for {
+		endpoint := fmt.Sprint(p.baseURL, "/models?pageSize=100")
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		httpReq, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+
+		// Send request and parse response
+		var modelsResp modelsResponse
+		if err := p.doRequest(httpReq, &modelsResp); err != nil {
+			return nil, err
+		}
+
+		// Embedding and other models cannot generate content
+		for _, model := range modelsResp.Models {
+			if supportsGenerateContent(model) {
+				models = append(models, strings.TrimPrefix(model.Name, "models/"))
+				registerModel(model)
+			}
+		}
+
+		if modelsResp.NextPageToken == "" {
+			break
+		}
+		pageToken = modelsResp.NextPageToken
+	}
+
+	return models, nil
+}
+
+// Ping implements the providers.Pinger interface by looking up the
+// configured model
+func (p *Provider) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprint(p.baseURL, "/models/", url.PathEscape(p.cfg.Model)), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	var info model
+	if err := p.doRequest(httpReq, &info); err != nil {
+		return err
+	}
+
+	registerModel(info)
+	return nil
+}
+
+// registerModel records the token limits the API reports for a model in the
+// model registry. The input limit excludes the answer, so the context window
+// is the sum of both limits.
+func registerModel(m model) {
+	if m.InputTokenLimit <= 0 || m.OutputTokenLimit <= 0 {
+		return
+	}
+
+	id := strings.TrimPrefix(m.Name, "models/")
+	info, ok := providers.LookupModel(id)
+	if !ok {
+		// Gemini models are multimodal
+		info = providers.ModelInfo{Vision: true, Tools: true}
+	}
+
+	info.ID = id
+	info.ContextWindow = m.InputTokenLimit + m.OutputTokenLimit
+	info.MaxOutputTokens = m.OutputTokenLimit
+	providers.RegisterModel(info)
+}
+
+// supportsGenerateContent reports whether a model can be used for chat
+func supportsGenerateContent(m model) bool {
+	for _, method := range m.SupportedGenerationMethods {
+		if method == "generateContent" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRequest creates an API request
+func (p *Provider) buildRequest(req *providers.Request) (*request, error) {
+	// Build system prompt with context
+	systemPrompt, err := req.SystemPrompt(p.cfg.SystemPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gemini calls the assistant role "model"
+	contents := make([]content, len(req.Messages))
+	for i, msg := range req.Messages {
+		role := msg.Role
+		if role == providers.RoleAssistant {
+			role = "model"
+		}
+		parts := []part{{Text: msg.Content}}
+		for _, image := range msg.Images {
+			parts = append(parts, part{InlineData: &inlineData{MimeType: image.MediaType, Data: image.Base64()}})
+		}
+		contents[i] = content{Role: role, Parts: parts}
+	}
+
+	return &request{
+		Contents:          contents,
+		SystemInstruction: &content{Parts: []part{{Text: systemPrompt}}},
+		GenerationConfig: &generationConfig{
+			MaxOutputTokens: req.MaxTokens(p.cfg.MaxTokens),
+			Temperature:     req.Temperature(p.cfg.Temperature),
+			TopP:            req.TopP(p.cfg.TopP),
+			StopSequences:   req.Options.StopSequences,
+		},
+	}, nil
+}
+
+// modelURL returns the endpoint URL of a method on the configured model
+func (p *Provider) modelURL(method string) string {
+	model := strings.TrimPrefix(p.cfg.Model, "models/")
+	return fmt.Sprintf("%s/models/%s:%s", p.baseURL, model, method)
+}
+
+// responseModel returns the model that produced a response
+func (p *Provider) responseModel(resp *response) string {
+	if resp.ModelVersion != "" {
+		return resp.ModelVersion
+	}
+	return p.cfg.Model
+}
+
+// text concatenates the text parts of the first candidate
+func (r *response) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// doRequest sends an HTTP request and parses the response into the provided struct
+func (p *Provider) doRequest(httpReq *http.Request, response interface{}) error {
+	p.setHeaders(httpReq)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// setHeaders adds the headers common to all API requests. The API key is
+// sent as a header rather than a query parameter to keep it out of URLs.
+func (p *Provider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.cfg.APIKey)
+
+	for key, value := range p.cfg.CustomHeaders {
+		httpReq.Header.Set(key, value)
+	}
+}
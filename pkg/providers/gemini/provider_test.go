@@ -0,0 +1,54 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// newTestProvider returns a provider sending its requests to handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewProvider(providers.Config{
+		Type:      ProviderName,
+		APIKey:    "test",
+		Model:     "gemini-2.5-flash",
+		BaseURL:   server.URL,
+		MaxTokens: 1000,
+		Retry:     &providers.RetryPolicy{MaxRetries: new(int)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider.(*Provider)
+}
+
+func TestSendCountsThinkingTokens(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"candidates": [{"content": {"parts": [{"text": "Use tar -xf."}]}, "finishReason": "STOP"}],
+			"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 5, "thoughtsTokenCount": 300, "totalTokenCount": 325},
+			"modelVersion": "gemini-2.5-flash-001"
+		}`)
+	})
+
+	resp, err := p.Send(context.Background(), providers.NewRequest("untar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Use tar -xf." || resp.Model != "gemini-2.5-flash-001" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.InputTokens != 20 || resp.OutputTokens != 305 || resp.TokensUsed != 325 {
+		t.Errorf("tokens = %d in, %d out, %d total; want the thinking billed as output",
+			resp.InputTokens, resp.OutputTokens, resp.TokensUsed)
+	}
+}
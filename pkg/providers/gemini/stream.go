@@ -0,0 +1,82 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// readStream consumes the server-sent events from body and emits chunks on out.
+// Each event is a complete GenerateContentResponse and the stream ends at EOF,
+// which completes the answer only after a candidate has finished.
+func (p *Provider) readStream(ctx context.Context, body io.ReadCloser, out chan<- providers.StreamResponse) {
+	defer close(out)
+	defer body.Close()
+
+	reader := providers.NewSSEReader(body)
+	metadata := map[string]interface{}{
+		providers.MetadataModel: p.cfg.Model,
+	}
+	finished := false
+
+	for {
+		sse, err := reader.Next()
+		if err == io.EOF && finished {
+			providers.SendChunk(ctx, out, providers.StreamResponse{
+				Done:     true,
+				Metadata: metadata,
+			})
+			return
+		}
+		if err == io.EOF {
+			providers.SendChunk(ctx, out, providers.StreamResponse{
+				Error: fmt.Errorf("%w: stream ended before the answer was complete", providers.ErrServiceUnavailable),
+			})
+			return
+		}
+		if err != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("failed to read stream: %w", err)})
+			return
+		}
+
+		var chunk struct {
+			response
+			Error *apiError `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal([]byte(sse.Data), &chunk); err != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("failed to decode stream event: %w", err)})
+			return
+		}
+
+		if chunk.Error != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: newAPIError(chunk.Error.Code, nil, *chunk.Error)})
+			return
+		}
+
+		if err := checkBlocked(&chunk.response); err != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+			return
+		}
+
+		if text := chunk.text(); text != "" {
+			if !providers.SendChunk(ctx, out, providers.StreamResponse{Text: text}) {
+				return
+			}
+		}
+
+		if chunk.ModelVersion != "" {
+			metadata[providers.MetadataModel] = chunk.ModelVersion
+		}
+		if len(chunk.Candidates) > 0 && chunk.Candidates[0].FinishReason != "" {
+			metadata[providers.MetadataStopReason] = chunk.Candidates[0].FinishReason
+			finished = true
+		}
+		if chunk.UsageMetadata != nil {
+			metadata[providers.MetadataInputTokens] = chunk.UsageMetadata.PromptTokenCount
+			metadata[providers.MetadataOutputTokens] = chunk.UsageMetadata.outputTokens()
+		}
+	}
+}
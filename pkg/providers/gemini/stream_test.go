@@ -0,0 +1,134 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// sseHandler answers with the given server-sent events, sending each line
+// of an event as a data field
+func sseHandler(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			for _, line := range strings.Split(event, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+		}
+	}
+}
+
+// piecesHandler answers with body cut into the given pieces, flushing after
+// each so the client reads them separately
+func piecesHandler(pieces ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range pieces {
+			fmt.Fprint(w, piece)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+// collect reads a stream to the end, returning its text, final chunk and
+// error, if any
+func collect(t *testing.T, p *Provider) (string, providers.StreamResponse, error) {
+	t.Helper()
+
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("untar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		text string
+		last providers.StreamResponse
+	)
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			return text, chunk, chunk.Error
+		}
+		text += chunk.Text
+		last = chunk
+	}
+	return text, last, nil
+}
+
+func TestStream(t *testing.T) {
+	p := newTestProvider(t, sseHandler(
+		`{"candidates": [{"content": {"parts": [{"text": "Use "}]}}]}`,
+		`{"candidates": [{"content": {"parts": [{"text": "tar -xf."}]}, "finishReason": "STOP"}],
+		  "usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 5, "thoughtsTokenCount": 300},
+		  "modelVersion": "gemini-2.5-flash-001"}`,
+	))
+
+	text, last, err := collect(t, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Use tar -xf." {
+		t.Errorf("streamed %q", text)
+	}
+	if !last.Done || last.Metadata[providers.MetadataStopReason] != "STOP" ||
+		last.Metadata[providers.MetadataInputTokens] != 20 || last.Metadata[providers.MetadataOutputTokens] != 305 {
+		t.Errorf("final chunk = %+v, want the thinking billed as output", last)
+	}
+}
+
+func TestStreamSplitAcrossReads(t *testing.T) {
+	first := `data: {"candidates": [{"content": {"parts": [{"text": "Use "}]}}]}` + "\r\n\r\n"
+	second := `data: {"candidates": [{"content": {"parts": [{"text": "tar -xf."}]}, "finishReason": "STOP"}]}` + "\r\n\r\n"
+	p := newTestProvider(t, piecesHandler(first[:12], first[12:len(first)-1], first[len(first)-1:]+second[:40], second[40:]))
+
+	text, last, err := collect(t, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Use tar -xf." || !last.Done {
+		t.Errorf("stream = %q, %+v; want both parts and the end", text, last)
+	}
+}
+
+func TestStreamEndingEarly(t *testing.T) {
+	p := newTestProvider(t, sseHandler(
+		`{"candidates": [{"content": {"parts": [{"text": "Use "}]}}]}`,
+	))
+
+	text, last, err := collect(t, p)
+	if !errors.Is(err, providers.ErrServiceUnavailable) || last.Done {
+		t.Errorf("stream ending without a finish reason = %q, %v; want the service to be unavailable", text, err)
+	}
+
+	// A body cut inside an event fails too
+	p = newTestProvider(t, piecesHandler(`data: {"candidates": [{"content": {"parts": [{"text": "Use "}]}}]}`+"\n\n", `data: {"candidates": [{"con`))
+	if text, last, err := collect(t, p); err == nil || last.Done || text != "Use " {
+		t.Errorf("stream cut inside an event = %q, %v; want an error after the first part", text, err)
+	}
+}
+
+func TestStreamErrorEvent(t *testing.T) {
+	tests := []struct {
+		event string
+		want  error
+	}{
+		{`{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED", "message": "Resource has been exhausted"}}`, providers.ErrRateLimitExceeded},
+		{`{"error": {"code": 503, "status": "UNAVAILABLE", "message": "The model is overloaded"}}`, providers.ErrServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		p := newTestProvider(t, sseHandler(`{"candidates": [{"content": {"parts": [{"text": "Use "}]}}]}`, tt.event))
+
+		_, _, err := collect(t, p)
+		var apiErr *providers.APIError
+		if !errors.Is(err, tt.want) || !errors.As(err, &apiErr) || apiErr.Provider != ProviderName {
+			t.Errorf("stream error = %v, want %v from the provider", err, tt.want)
+		}
+	}
+}
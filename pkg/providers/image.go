@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MaxImageSize is the largest image file that can be attached. Providers
+// may accept less, see Capabilities.MaxImageSize.
+const MaxImageSize = 20 * 1024 * 1024
+
+// ErrImagesNotSupported is returned when a request with images is sent to a
+// provider without image analysis
+var ErrImagesNotSupported = fmt.Errorf("provider cannot analyze images")
+
+// ErrImageTooLarge is returned when a request has an image larger than the
+// provider accepts
+var ErrImageTooLarge = fmt.Errorf("image too large for provider")
+
+// Image is an image attached to a message
+type Image struct {
+	// MediaType is the MIME type: image/png, image/jpeg, image/gif or image/webp
+	MediaType string `json:"media_type"`
+	Data      []byte `json:"data"`
+}
+
+// Base64 returns the image data encoded as standard base64
+func (i Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// DataURL returns the image as a data: URL
+func (i Image) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", i.MediaType, i.Base64())
+}
+
+// LoadImage reads an image file, detecting its type from its contents
+func LoadImage(path string) (Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if info.Size() > MaxImageSize {
+		return Image{}, fmt.Errorf("image %s is too large (%d MB, the limit is %d MB)",
+			filepath.Base(path), info.Size()/(1024*1024), MaxImageSize/(1024*1024))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	mediaType := detectImageType(data)
+	if mediaType == "" {
+		return Image{}, fmt.Errorf("%s is not a PNG, JPEG, GIF or WebP image", filepath.Base(path))
+	}
+
+	return Image{MediaType: mediaType, Data: data}, nil
+}
+
+// detectImageType returns the MIME type of a supported image format, or an
+// empty string for other data
+func detectImageType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "image/gif"
+	case len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "image/webp"
+	default:
+		return ""
+	}
+}
+
+// HasImages reports whether any message of the request has images attached
+func (r *Request) HasImages() bool {
+	for _, msg := range r.Messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// LargestImage returns the size in bytes of the largest image attached to
+// the request, or zero when there is none
+func (r *Request) LargestImage() int {
+	largest := 0
+	for _, msg := range r.Messages {
+		for _, image := range msg.Images {
+			if len(image.Data) > largest {
+				largest = len(image.Data)
+			}
+		}
+	}
+	return largest
+}
+
+// FormatImageSize formats a size in bytes in megabytes
+func FormatImageSize(size int) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
@@ -1,5 +1,7 @@
 package providers
 
+import "context"
+
 // Provider defines the interface that all AI providers must implement
 type Provider interface {
 	// SendPrompt sends a prompt with context to the AI provider
@@ -20,3 +22,11 @@ type Provider interface {
 	// GetModels returns a list of available models
 	GetModels() ([]string, error)
 }
+
+// Pinger is implemented by providers that can check with a cheap real call
+// that their API is reachable, the credentials are accepted and the
+// configured model is available. Ping fails with ErrInvalidAPIKey or
+// ErrInvalidModel when either is not.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixtures is the scripted behavior of the mock provider, loaded from a YAML
+// or JSON file
+type Fixtures struct {
+	// Model reported in responses
+	Model string `yaml:"model"`
+
+	// Default latency before answering and delay between streamed chunks
+	Latency    time.Duration `yaml:"latency"`
+	ChunkDelay time.Duration `yaml:"chunkDelay"`
+
+	// Responses are matched in order against the prompt
+	Responses []Fixture `yaml:"responses"`
+
+	// Default is used when no response matches
+	Default *Fixture `yaml:"default"`
+}
+
+// Fixture maps prompts matching a regular expression to a canned answer
+type Fixture struct {
+	Match    string        `yaml:"match"`
+	Response string        `yaml:"response"`
+	Latency  time.Duration `yaml:"latency"`
+
+	// Error injects a failure instead of the response, either one of the
+	// names in errorsByName or a free-form message
+	Error string `yaml:"error"`
+
+	// ErrorAfterChunks delays an injected error in streaming mode until the
+	// given number of chunks has been sent
+	ErrorAfterChunks int `yaml:"errorAfterChunks"`
+
+	pattern *regexp.Regexp
+}
+
+// errorsByName maps fixture error names to the sentinel errors providers return
+var errorsByName = map[string]error{
+	"invalid_api_key":   providers.ErrInvalidAPIKey,
+	"invalid_model":     providers.ErrInvalidModel,
+	"rate_limit":        providers.ErrRateLimitExceeded,
+	"quota":             providers.ErrQuotaExceeded,
+	"unavailable":       providers.ErrServiceUnavailable,
+	"context_too_large": providers.ErrContextTooLarge,
+	"content_blocked":   providers.ErrContentBlocked,
+}
+
+// defaultResponse is answered when no fixture file is configured
+const defaultResponse = `This is a response from the mock provider.
+
+List the files in the current directory:
+
+` + "```bash\nls -la\n```" + `
+
+<structured_commands>
+{
+  "commands": [
+    {
+      "command": "ls -la",
+      "description": "List all files with detailed information",
+      "category": "file",
+      "safe": true,
+      "required": false,
+      "order": 1
+    }
+  ],
+  "workflows": []
+}
+</structured_commands>`
+
+// LoadFixtures reads and validates a fixture file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	// YAML is a superset of JSON, so both formats decode here
+	var fixtures Fixtures
+	if err := yaml.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+
+	for i := range fixtures.Responses {
+		fixture := &fixtures.Responses[i]
+		pattern, err := regexp.Compile(fixture.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern %q in fixture %d: %w", fixture.Match, i+1, err)
+		}
+		fixture.pattern = pattern
+	}
+
+	return &fixtures, nil
+}
+
+// defaultFixtures are used when no fixture file is configured
+func defaultFixtures() *Fixtures {
+	return &Fixtures{
+		Default: &Fixture{Response: defaultResponse},
+	}
+}
+
+// find returns the first fixture matching the prompt
+func (f *Fixtures) find(prompt string) *Fixture {
+	for i := range f.Responses {
+		if f.Responses[i].pattern.MatchString(prompt) {
+			return &f.Responses[i]
+		}
+	}
+
+	if f.Default != nil {
+		return f.Default
+	}
+
+	return &Fixture{Error: fmt.Sprintf("no fixture matches prompt %q", prompt)}
+}
+
+// latency returns how long to wait before answering with fixture
+func (f *Fixtures) latency(fixture *Fixture) time.Duration {
+	if fixture.Latency > 0 {
+		return fixture.Latency
+	}
+	return f.Latency
+}
+
+// err returns the injected error of a fixture, if any
+func (fixture *Fixture) err() error {
+	if fixture.Error == "" {
+		return nil
+	}
+
+	if sentinel, ok := errorsByName[fixture.Error]; ok {
+		return fmt.Errorf("%w (injected by mock fixture)", sentinel)
+	}
+
+	return fmt.Errorf("%s", fixture.Error)
+}
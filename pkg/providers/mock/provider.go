@@ -0,0 +1,226 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider implements the providers.Provider interface with deterministic
+// answers from a fixture file, for offline demos and tests
+type Provider struct {
+	cfg      providers.Config
+	fixtures *Fixtures
+}
+
+const (
+	// ProviderName is mock provider name
+	ProviderName = "mock"
+	defaultModel = "mock-model"
+	displayName  = "Mock Provider"
+	description  = "Deterministic answers from scripted fixtures, no network access."
+)
+
+// NewProvider creates a new mock provider instance
+func NewProvider(cfg providers.Config) (providers.Provider, error) {
+	fixtures := defaultFixtures()
+	if cfg.Fixtures != "" {
+		loaded, err := LoadFixtures(cfg.Fixtures)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = loaded
+	}
+
+	return &Provider{
+		cfg:      cfg,
+		fixtures: fixtures,
+	}, nil
+}
+
+// SendPrompt implements the providers.Provider interface
+func (p *Provider) SendPrompt(prompt string, promptContext *providers.Context) (*providers.Response, error) {
+	return p.Send(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// SendPromptStream implements streaming for the providers.Provider interface
+func (p *Provider) SendPromptStream(prompt string, promptContext *providers.Context) (<-chan providers.StreamResponse, error) {
+	return p.Stream(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	startTime := time.Now()
+	prompt := req.Prompt()
+
+	fixture := p.fixtures.find(prompt)
+	if err := sleep(ctx, p.fixtures.latency(fixture)); err != nil {
+		return nil, err
+	}
+
+	if err := fixture.err(); err != nil {
+		return nil, err
+	}
+
+	inputTokens := countTokens(prompt)
+	outputTokens := countTokens(fixture.Response)
+
+	response := &providers.Response{
+		Text:         fixture.Response,
+		Model:        p.model(),
+		Provider:     ProviderName,
+		TokensUsed:   inputTokens + outputTokens,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		ResponseTime: time.Since(startTime),
+	}
+
+	return response, nil
+}
+
+// Stream implements the providers.ProviderV2 interface
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	prompt := req.Prompt()
+	fixture := p.fixtures.find(prompt)
+
+	// Errors injected without a chunk count fail the request itself
+	if err := fixture.err(); err != nil && fixture.ErrorAfterChunks == 0 {
+		if err := sleep(ctx, p.fixtures.latency(fixture)); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse)
+	go p.stream(ctx, prompt, fixture, chunks)
+
+	return chunks, nil
+}
+
+// stream emits the fixture response word by word on out
+func (p *Provider) stream(ctx context.Context, prompt string, fixture *Fixture, out chan<- providers.StreamResponse) {
+	defer close(out)
+
+	if err := sleep(ctx, p.fixtures.latency(fixture)); err != nil {
+		return
+	}
+
+	for i, chunk := range splitChunks(fixture.Response) {
+		if err := fixture.err(); err != nil && i == fixture.ErrorAfterChunks {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+			return
+		}
+
+		if i > 0 {
+			if err := sleep(ctx, p.fixtures.ChunkDelay); err != nil {
+				return
+			}
+		}
+		if !providers.SendChunk(ctx, out, providers.StreamResponse{Text: chunk}) {
+			return
+		}
+	}
+
+	if err := fixture.err(); err != nil {
+		providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+		return
+	}
+
+	providers.SendChunk(ctx, out, providers.StreamResponse{
+		Done: true,
+		Metadata: map[string]interface{}{
+			providers.MetadataModel:        p.model(),
+			providers.MetadataStopReason:   "end_turn",
+			providers.MetadataInputTokens:  countTokens(prompt),
+			providers.MetadataOutputTokens: countTokens(fixture.Response),
+		},
+	})
+}
+
+// ValidateConfig implements the providers.Provider interface
+func (p *Provider) ValidateConfig() error {
+	if p.fixtures == nil {
+		return fmt.Errorf("no fixtures loaded")
+	}
+	return nil
+}
+
+// GetInfo implements the providers.Provider interface
+func (p *Provider) GetInfo() providers.ProviderInfo {
+	return providers.ProviderInfo{
+		Name:        displayName,
+		Type:        ProviderName,
+		Model:       p.model(),
+		Description: description,
+	}
+}
+
+// GetCapabilities implements the providers.Provider interface
+func (p *Provider) GetCapabilities() providers.Capabilities {
+	return p.cfg.Capabilities.Apply(providers.Capabilities{
+		Streaming:          true,
+		FunctionCalling:    false,
+		CodeExecution:      false,
+		ImageAnalysis:      false,
+		ConversationMemory: true,
+		MaxContextSize:     200000,
+		MaxTokens:          4096,
+	})
+}
+
+// GetModels implements the providers.Provider interface
+func (p *Provider) GetModels() ([]string, error) {
+	return []string{p.model()}, nil
+}
+
+// Ping implements the providers.Pinger interface. The fixtures are always
+// reachable.
+func (p *Provider) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// model returns the model name reported in responses
+func (p *Provider) model() string {
+	switch {
+	case p.cfg.Model != "":
+		return p.cfg.Model
+	case p.fixtures.Model != "":
+		return p.fixtures.Model
+	default:
+		return defaultModel
+	}
+}
+
+// chunkPattern matches a word together with the whitespace before it
+var chunkPattern = regexp.MustCompile(`\s*\S+|\s+$`)
+
+// splitChunks splits text into words, keeping the whitespace before each word
+func splitChunks(text string) []string {
+	return chunkPattern.FindAllString(text, -1)
+}
+
+// sleep waits for d unless ctx is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// countTokens approximates a token count from the number of words
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
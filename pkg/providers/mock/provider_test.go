@@ -0,0 +1,191 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+func newTestProvider(t *testing.T, cfg providers.Config) *Provider {
+	t.Helper()
+
+	cfg.Type = ProviderName
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider.(*Provider)
+}
+
+func TestSendMatchesFixtures(t *testing.T) {
+	p := newTestProvider(t, providers.Config{Fixtures: "testdata/fixtures.yaml"})
+
+	resp, err := p.Send(context.Background(), providers.NewRequest("how do I untar a zst file?", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Text, "tar --zstd -xf archive.tar.zst") || resp.Model != "fixture-model" || resp.Provider != ProviderName {
+		t.Errorf("response = %+v, want the untar fixture", resp)
+	}
+	if resp.InputTokens != 7 || resp.TokensUsed != resp.InputTokens+resp.OutputTokens {
+		t.Errorf("tokens = %d in, %d out, %d total", resp.InputTokens, resp.OutputTokens, resp.TokensUsed)
+	}
+
+	resp, err = p.Send(context.Background(), providers.NewRequest("what is the meaning of life?", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "I don't know." {
+		t.Errorf("unmatched prompt answered %q, want the default", resp.Text)
+	}
+
+	// The configured model takes precedence over the fixtures'
+	p = newTestProvider(t, providers.Config{Fixtures: "testdata/fixtures.yaml", Model: "configured"})
+	if resp, _ := p.Send(context.Background(), providers.NewRequest("hi", nil)); resp.Model != "configured" {
+		t.Errorf("model = %q, want the configured one", resp.Model)
+	}
+}
+
+func TestDefaultFixtures(t *testing.T) {
+	p := newTestProvider(t, providers.Config{})
+
+	resp, err := p.Send(context.Background(), providers.NewRequest("anything", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Text, "<structured_commands>") || resp.Model != defaultModel {
+		t.Errorf("response = %+v, want the built-in answer", resp)
+	}
+}
+
+// collect reads a stream to the end, returning its text, final chunk and
+// error, if any
+func collect(t *testing.T, chunks <-chan providers.StreamResponse) (string, providers.StreamResponse, error) {
+	t.Helper()
+
+	var (
+		text string
+		last providers.StreamResponse
+	)
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			return text, chunk, chunk.Error
+		}
+		text += chunk.Text
+		last = chunk
+	}
+	return text, last, nil
+}
+
+func TestStream(t *testing.T) {
+	p := newTestProvider(t, providers.Config{Fixtures: "testdata/fixtures.yaml"})
+
+	want, err := p.Send(context.Background(), providers.NewRequest("untar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("untar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, last, err := collect(t, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text != want.Text {
+		t.Errorf("streamed %q, want %q", text, want.Text)
+	}
+	if !last.Done || last.Metadata[providers.MetadataModel] != "fixture-model" || last.Metadata[providers.MetadataOutputTokens] != want.OutputTokens {
+		t.Errorf("final chunk = %+v, want the response's metadata", last)
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	for _, text := range []string{"", "one", "  two words ", "lines\n\n  and\ttabs\n"} {
+		if got := strings.Join(splitChunks(text), ""); got != text {
+			t.Errorf("splitChunks(%q) joins back to %q", text, got)
+		}
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	p := newTestProvider(t, providers.Config{Fixtures: "testdata/fixtures.yaml"})
+
+	_, err := p.Send(context.Background(), providers.NewRequest("overloaded", nil))
+	if !errors.Is(err, providers.ErrServiceUnavailable) {
+		t.Errorf("Send = %v, want the service to be unavailable", err)
+	}
+
+	// Without a chunk count, the stream fails to start
+	if _, err := p.Stream(context.Background(), providers.NewRequest("overloaded", nil)); !errors.Is(err, providers.ErrServiceUnavailable) {
+		t.Errorf("Stream = %v, want the service to be unavailable", err)
+	}
+
+	// With one, it fails part way through
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("midstream", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _, err := collect(t, chunks)
+	if text != "one two" || !errors.Is(err, providers.ErrRateLimitExceeded) {
+		t.Errorf("stream = %q, %v; want two chunks and a rate limit error", text, err)
+	}
+
+	// Errors without a known name are passed on as they are
+	_, err = p.Send(context.Background(), providers.NewRequest("teapot", nil))
+	if err == nil || err.Error() != "I'm a teapot" {
+		t.Errorf("Send = %v, want the fixture's message", err)
+	}
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	p := newTestProvider(t, providers.Config{Fixtures: "testdata/fixtures.yaml"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.Send(ctx, providers.NewRequest("slow", nil)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send = %v, want the deadline to be exceeded", err)
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// JSON decodes as well as YAML
+	fixtures, err := LoadFixtures(write("fixtures.json", `{"responses": [{"match": "^hi$", "response": "hello"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fixtures.find("hi"); got.Response != "hello" {
+		t.Errorf("find(hi) = %+v", got)
+	}
+
+	// Without a default, unmatched prompts fail
+	if err := fixtures.find("bye").err(); err == nil || !strings.Contains(err.Error(), "no fixture matches") {
+		t.Errorf("unmatched prompt error = %v", err)
+	}
+
+	if _, err := LoadFixtures(write("invalid.yaml", "responses:\n  - match: \"(\"\n")); err == nil {
+		t.Error("an invalid match pattern was accepted")
+	}
+
+	if _, err := NewProvider(providers.Config{Fixtures: filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("a missing fixture file was accepted")
+	}
+}
@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ModelInfo describes the limits, features and price of a model
+type ModelInfo struct {
+	// ID is the model name, or a prefix matching a family of dated versions
+	ID string `json:"id"`
+
+	ContextWindow   int  `json:"context_window,omitempty"`
+	MaxOutputTokens int  `json:"max_output_tokens,omitempty"`
+	Vision          bool `json:"vision,omitempty"`
+	Tools           bool `json:"tools,omitempty"`
+
+	// Prices in US dollars per million tokens; zero when unknown
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+}
+
+// Priced reports whether the price of the model is known
+func (m ModelInfo) Priced() bool {
+	return m.InputPrice > 0 || m.OutputPrice > 0
+}
+
+// builtinModels are the hosted models known without asking their provider
+var builtinModels = []ModelInfo{
+	{ID: "claude-opus-4-5", ContextWindow: 200000, MaxOutputTokens: 64000, Vision: true, Tools: true, InputPrice: 5, OutputPrice: 25},
+	{ID: "claude-opus-4", ContextWindow: 200000, MaxOutputTokens: 32000, Vision: true, Tools: true, InputPrice: 15, OutputPrice: 75},
+	{ID: "claude-sonnet-4", ContextWindow: 200000, MaxOutputTokens: 64000, Vision: true, Tools: true, InputPrice: 3, OutputPrice: 15},
+	{ID: "claude-haiku-4", ContextWindow: 200000, MaxOutputTokens: 64000, Vision: true, Tools: true, InputPrice: 1, OutputPrice: 5},
+	{ID: "claude-3-7-sonnet", ContextWindow: 200000, MaxOutputTokens: 64000, Vision: true, Tools: true, InputPrice: 3, OutputPrice: 15},
+	{ID: "claude-3-5-sonnet", ContextWindow: 200000, MaxOutputTokens: 8192, Vision: true, Tools: true, InputPrice: 3, OutputPrice: 15},
+	{ID: "claude-3-5-haiku", ContextWindow: 200000, MaxOutputTokens: 8192, Tools: true, InputPrice: 0.8, OutputPrice: 4},
+	{ID: "claude-3-opus", ContextWindow: 200000, MaxOutputTokens: 4096, Vision: true, Tools: true, InputPrice: 15, OutputPrice: 75},
+	{ID: "claude-3-haiku", ContextWindow: 200000, MaxOutputTokens: 4096, Vision: true, Tools: true, InputPrice: 0.25, OutputPrice: 1.25},
+
+	{ID: "gpt-5", ContextWindow: 400000, MaxOutputTokens: 128000, Vision: true, Tools: true, InputPrice: 1.25, OutputPrice: 10},
+	{ID: "gpt-5-mini", ContextWindow: 400000, MaxOutputTokens: 128000, Vision: true, Tools: true, InputPrice: 0.25, OutputPrice: 2},
+	{ID: "gpt-5-nano", ContextWindow: 400000, MaxOutputTokens: 128000, Vision: true, Tools: true, InputPrice: 0.05, OutputPrice: 0.4},
+	{ID: "gpt-4.1", ContextWindow: 1047576, MaxOutputTokens: 32768, Vision: true, Tools: true, InputPrice: 2, OutputPrice: 8},
+	{ID: "gpt-4.1-mini", ContextWindow: 1047576, MaxOutputTokens: 32768, Vision: true, Tools: true, InputPrice: 0.4, OutputPrice: 1.6},
+	{ID: "gpt-4.1-nano", ContextWindow: 1047576, MaxOutputTokens: 32768, Vision: true, Tools: true, InputPrice: 0.1, OutputPrice: 0.4},
+	{ID: "gpt-4o", ContextWindow: 128000, MaxOutputTokens: 16384, Vision: true, Tools: true, InputPrice: 2.5, OutputPrice: 10},
+	{ID: "gpt-4o-mini", ContextWindow: 128000, MaxOutputTokens: 16384, Vision: true, Tools: true, InputPrice: 0.15, OutputPrice: 0.6},
+	{ID: "gpt-4-turbo", ContextWindow: 128000, MaxOutputTokens: 4096, Vision: true, Tools: true, InputPrice: 10, OutputPrice: 30},
+	{ID: "gpt-4", ContextWindow: 8192, MaxOutputTokens: 8192, Tools: true, InputPrice: 30, OutputPrice: 60},
+	{ID: "gpt-3.5-turbo", ContextWindow: 16385, MaxOutputTokens: 4096, Tools: true, InputPrice: 0.5, OutputPrice: 1.5},
+	{ID: "o1", ContextWindow: 200000, MaxOutputTokens: 100000, Vision: true, Tools: true, InputPrice: 15, OutputPrice: 60},
+	{ID: "o3", ContextWindow: 200000, MaxOutputTokens: 100000, Vision: true, Tools: true, InputPrice: 2, OutputPrice: 8},
+	{ID: "o3-mini", ContextWindow: 200000, MaxOutputTokens: 100000, Tools: true, InputPrice: 1.1, OutputPrice: 4.4},
+	{ID: "o4-mini", ContextWindow: 200000, MaxOutputTokens: 100000, Vision: true, Tools: true, InputPrice: 1.1, OutputPrice: 4.4},
+
+	{ID: "gemini-2.5-pro", ContextWindow: 1048576, MaxOutputTokens: 65536, Vision: true, Tools: true, InputPrice: 1.25, OutputPrice: 10},
+	{ID: "gemini-2.5-flash", ContextWindow: 1048576, MaxOutputTokens: 65536, Vision: true, Tools: true, InputPrice: 0.3, OutputPrice: 2.5},
+	{ID: "gemini-2.5-flash-lite", ContextWindow: 1048576, MaxOutputTokens: 65536, Vision: true, Tools: true, InputPrice: 0.1, OutputPrice: 0.4},
+	{ID: "gemini-2.0-flash", ContextWindow: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, InputPrice: 0.1, OutputPrice: 0.4},
+	{ID: "gemini-2.0-flash-lite", ContextWindow: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, InputPrice: 0.075, OutputPrice: 0.3},
+	{ID: "gemini-1.5-pro", ContextWindow: 2097152, MaxOutputTokens: 8192, Vision: true, Tools: true, InputPrice: 1.25, OutputPrice: 5},
+	{ID: "gemini-1.5-flash", ContextWindow: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, InputPrice: 0.075, OutputPrice: 0.3},
+}
+
+// modelRegistry holds the known models by ID. Entries registered at run
+// time, from configuration or provider metadata, replace built-in ones.
+var modelRegistry = struct {
+	sync.RWMutex
+	models map[string]ModelInfo
+}{models: make(map[string]ModelInfo)}
+
+func init() {
+	for _, model := range builtinModels {
+		modelRegistry.models[model.ID] = model
+	}
+}
+
+// RegisterModel adds or replaces a model in the registry
+func RegisterModel(model ModelInfo) {
+	modelRegistry.Lock()
+	defer modelRegistry.Unlock()
+	modelRegistry.models[model.ID] = model
+}
+
+// LookupModel returns the registry entry with the longest ID that prefixes
+// model, so that dated versions such as claude-sonnet-4-20250514 share their
+// family's entry. A path before the name, as in "models/gemini-2.0-flash",
+// is ignored.
+func LookupModel(model string) (ModelInfo, bool) {
+	model = model[strings.LastIndex(model, "/")+1:]
+
+	modelRegistry.RLock()
+	defer modelRegistry.RUnlock()
+
+	var best ModelInfo
+	found := false
+	for id, info := range modelRegistry.models {
+		if strings.HasPrefix(model, id) && len(id) > len(best.ID) {
+			best, found = info, true
+		}
+	}
+
+	return best, found
+}
+
+// ModelCapabilities adjusts a provider's capabilities to a model's entry in
+// the registry. Features stay disabled when the provider lacks support for
+// them, whatever the model supports.
+func ModelCapabilities(model string, caps Capabilities) Capabilities {
+	info, ok := LookupModel(model)
+	if !ok {
+		return caps
+	}
+
+	if info.ContextWindow > 0 {
+		caps.MaxContextSize = info.ContextWindow
+	}
+	if info.MaxOutputTokens > 0 {
+		caps.MaxTokens = info.MaxOutputTokens
+	}
+	caps.ImageAnalysis = caps.ImageAnalysis && info.Vision
+	caps.FunctionCalling = caps.FunctionCalling && info.Tools
+
+	return caps
+}
+
+// ValidateMaxTokens checks the configured output token limit against the
+// model's limit, declared in the capability overrides or the registry
+func (c Config) ValidateMaxTokens() error {
+	if c.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive, got %d", c.MaxTokens)
+	}
+
+	limit := 0
+	if info, ok := LookupModel(c.Model); ok {
+		limit = info.MaxOutputTokens
+	}
+	if c.Capabilities != nil && c.Capabilities.MaxTokens > 0 {
+		limit = c.Capabilities.MaxTokens
+	}
+
+	if limit > 0 && c.MaxTokens > limit {
+		return fmt.Errorf("max_tokens must be between 1 and %d for %s, got %d", limit, c.Model, c.MaxTokens)
+	}
+
+	return nil
+}
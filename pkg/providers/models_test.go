@@ -0,0 +1,90 @@
+package providers
+
+import "testing"
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"claude-sonnet-4-20250514", "claude-sonnet-4"},
+		{"claude-3-5-haiku-latest", "claude-3-5-haiku"},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini"},
+		{"gpt-4o-2024-08-06", "gpt-4o"},
+		{"gpt-4-0613", "gpt-4"},
+		{"gpt-4.1-nano", "gpt-4.1-nano"},
+		{"o3-mini", "o3-mini"},
+		{"o3", "o3"},
+		{"models/gemini-2.0-flash-001", "gemini-2.0-flash"},
+		{"gemini-2.5-flash-lite-preview", "gemini-2.5-flash-lite"},
+		{"llama3.1:8b", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		info, found := LookupModel(tt.model)
+		if found != (tt.want != "") || info.ID != tt.want {
+			t.Errorf("LookupModel(%q) = %q, %v; want %q", tt.model, info.ID, found, tt.want)
+		}
+	}
+}
+
+func TestRegisterModelReplacesBuiltin(t *testing.T) {
+	builtin, _ := LookupModel("gpt-4o")
+	defer RegisterModel(builtin)
+
+	RegisterModel(ModelInfo{ID: "gpt-4o", ContextWindow: 64000})
+	info, _ := LookupModel("gpt-4o-2024-08-06")
+	if info.ContextWindow != 64000 {
+		t.Errorf("context window = %d, want the registered one", info.ContextWindow)
+	}
+
+	RegisterModel(ModelInfo{ID: "test-local-model", ContextWindow: 8192})
+	defer func() {
+		modelRegistry.Lock()
+		delete(modelRegistry.models, "test-local-model")
+		modelRegistry.Unlock()
+	}()
+	if info, ok := LookupModel("test-local-model-q4"); !ok || info.ContextWindow != 8192 {
+		t.Errorf("LookupModel of a registered family = %+v, %v", info, ok)
+	}
+}
+
+func TestModelCapabilities(t *testing.T) {
+	full := Capabilities{Streaming: true, FunctionCalling: true, ImageAnalysis: true, MaxContextSize: 1000, MaxTokens: 100}
+
+	caps := ModelCapabilities("claude-3-5-haiku-20241022", full)
+	if caps.ImageAnalysis || !caps.FunctionCalling || caps.MaxContextSize != 200000 || caps.MaxTokens != 8192 {
+		t.Errorf("capabilities = %+v, want those of claude-3-5-haiku", caps)
+	}
+
+	// A model's features do not enable what the provider lacks
+	caps = ModelCapabilities("gpt-4o", Capabilities{})
+	if caps.ImageAnalysis || caps.FunctionCalling {
+		t.Errorf("capabilities = %+v, want the provider's limits kept", caps)
+	}
+
+	if caps := ModelCapabilities("unknown-model", full); caps != full {
+		t.Errorf("capabilities of an unknown model = %+v, want the provider's", caps)
+	}
+}
+
+func TestValidateMaxTokens(t *testing.T) {
+	tests := []struct {
+		cfg   Config
+		valid bool
+	}{
+		{Config{Model: "gpt-4", MaxTokens: 8192}, true},
+		{Config{Model: "gpt-4", MaxTokens: 8193}, false},
+		{Config{Model: "unknown", MaxTokens: 1000000}, true},
+		{Config{Model: "gpt-4", MaxTokens: 0}, false},
+		{Config{Model: "gpt-4", MaxTokens: 10000, Capabilities: &CapabilityOverrides{MaxTokens: 16000}}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.cfg.ValidateMaxTokens()
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateMaxTokens(%s, %d) = %v, want valid %v", tt.cfg.Model, tt.cfg.MaxTokens, err, tt.valid)
+		}
+	}
+}
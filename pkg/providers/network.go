@@ -0,0 +1,227 @@
+package providers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// NetworkConfig controls how HTTP-based providers connect to their API
+type NetworkConfig struct {
+	// ConnectTimeout limits establishing a connection; zero leaves the
+	// system default
+	ConnectTimeout time.Duration `json:"connect_timeout,omitempty"`
+
+	// Proxy is the URL of an HTTP(S) proxy used for all requests. When
+	// empty, HTTPS_PROXY, HTTP_PROXY and NO_PROXY are respected; "direct"
+	// ignores them.
+	Proxy string `json:"proxy,omitempty"`
+
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system ones, e.g. that of an intercepting proxy
+	CAFile string `json:"ca_file,omitempty"`
+
+	// ClientCert and ClientKey are PEM files presented for mutual TLS
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+
+	// TLSMinVersion is the oldest TLS version accepted: "1.0" to "1.3"
+	TLSMinVersion string `json:"tls_min_version,omitempty"`
+}
+
+// proxyDirect disables the proxy from the environment
+const proxyDirect = "direct"
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// HTTPClient returns an HTTP client with the configured timeout and network
+// settings. defaultTimeout applies when no timeout is configured.
+func (c Config) HTTPClient(defaultTimeout time.Duration) (*http.Client, error) {
+	timeout := defaultTimeout
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+
+	transport, err := c.Network.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// StreamClient returns a copy of client for streaming. A long answer can
+// legitimately take longer to stream than the client's timeout, so instead
+// of limiting the whole request, the timeout limits the wait for the
+// response headers and for each read of the body.
+func StreamClient(client *http.Client) *http.Client {
+	stream := *client
+	stream.Timeout = 0
+	if client.Timeout <= 0 {
+		return &stream
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.ResponseHeaderTimeout = client.Timeout
+
+	stream.Transport = &idleTimeoutTransport{next: transport, timeout: client.Timeout}
+	return &stream
+}
+
+// idleTimeoutTransport gives up on response bodies that stop sending data
+type idleTimeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = newIdleTimeoutBody(resp.Body, t.timeout)
+	return resp, nil
+}
+
+// idleTimeoutBody closes a response body that sends no data for timeout, so
+// that a stalled connection fails the stream instead of hanging it
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut int32
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.timedOut, 1)
+		body.Close()
+	})
+	return b
+}
+
+// Read implements the io.Reader interface. A stalled body is reported as
+// the service being unavailable, so that it is retried or fallen back from.
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if atomic.LoadInt32(&b.timedOut) == 1 {
+		return n, fmt.Errorf("%w: no data received for %s", ErrServiceUnavailable, b.timeout)
+	}
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+// Close implements the io.Closer interface
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
+
+// transport builds an HTTP transport from the network settings, starting
+// from the defaults of http.DefaultTransport
+func (n *NetworkConfig) transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if n == nil {
+		return transport, nil
+	}
+
+	if n.ConnectTimeout < 0 {
+		return nil, fmt.Errorf("connect timeout must not be negative, got %s", n.ConnectTimeout)
+	}
+	if n.ConnectTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   n.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	switch n.Proxy {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case proxyDirect:
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(n.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", n.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := n.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// tlsConfig builds the TLS settings: trusted authorities, client
+// certificate and minimum version
+func (n *NetworkConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if n.TLSMinVersion != "" {
+		version, ok := tlsVersions[n.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", n.TLSMinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if n.CAFile != "" {
+		pem, err := os.ReadFile(n.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", n.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if n.ClientCert != "" || n.ClientKey != "" {
+		if n.ClientCert == "" || n.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(n.ClientCert, n.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamClient(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		switch r.URL.Path {
+		case "/no-headers":
+			time.Sleep(3 * timeout)
+		case "/stalled":
+			fmt.Fprint(w, "data: first\n")
+			flusher.Flush()
+			time.Sleep(3 * timeout)
+		case "/slow":
+			// Longer than the timeout overall, but never idle for that long
+			for i := 0; i < 6; i++ {
+				fmt.Fprint(w, "data: chunk\n")
+				flusher.Flush()
+				time.Sleep(timeout / 4)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := StreamClient(&http.Client{Timeout: timeout})
+
+	t.Run("no headers", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/no-headers")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("request without response headers succeeded")
+		}
+	})
+
+	t.Run("stalled body", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/stalled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		_, err = io.ReadAll(resp.Body)
+		if !errors.Is(err, ErrServiceUnavailable) {
+			t.Fatalf("reading a stalled body = %v, want the service to be unavailable", err)
+		}
+	})
+
+	t.Run("slow body", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/slow")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading a slow but steady body: %v", err)
+		}
+		if len(data) != 6*len("data: chunk\n") {
+			t.Errorf("read %d bytes, want the whole body", len(data))
+		}
+	})
+}
@@ -0,0 +1,367 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider implements the providers.Provider interface for a local Ollama server
+type Provider struct {
+	httpClient *http.Client
+	baseURL    string
+	cfg        providers.Config
+}
+
+// API request/response structures
+type request struct {
+	Model    string    `json:"model"`
+	Messages []message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Options  *options  `json:"options,omitempty"`
+}
+
+type options struct {
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// Base64 encoded images, for vision models such as llava
+	Images []string `json:"images,omitempty"`
+}
+
+type response struct {
+	Model           string  `json:"model"`
+	CreatedAt       string  `json:"created_at"`
+	Message         message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// tagsResponse represents the response from the tags API endpoint
+type tagsResponse struct {
+	Models []model `json:"models"`
+}
+
+type model struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	ModifiedAt string `json:"modified_at"`
+	Size       int64  `json:"size"`
+}
+
+const (
+	// ProviderName is ollama local model provider name
+	ProviderName = "ollama"
+	baseURL      = "http://localhost:11434"
+	displayName  = "Ollama"
+	description  = "Local models served by Ollama, no API key or network access required."
+
+	// maxTemperature is the highest sampling temperature the API accepts
+	maxTemperature = 2
+
+	// defaultTimeout limits a request unless a timeout is configured. Local
+	// models running on a CPU can take minutes to answer.
+	defaultTimeout = 5 * time.Minute
+)
+
+// NewProvider creates a new Ollama provider instance
+func NewProvider(cfg providers.Config) (providers.Provider, error) {
+	url := baseURL
+	if cfg.BaseURL != "" {
+		url = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
+	httpClient, err := cfg.HTTPClient(defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		httpClient: httpClient,
+		cfg:        cfg,
+		baseURL:    url,
+	}, nil
+}
+
+// SendPrompt implements the providers.Provider interface
+func (p *Provider) SendPrompt(prompt string, promptContext *providers.Context) (*providers.Response, error) {
+	return p.Send(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// SendPromptStream implements streaming for the providers.Provider interface
+func (p *Provider) SendPromptStream(prompt string, promptContext *providers.Context) (<-chan providers.StreamResponse, error) {
+	return p.Stream(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	startTime := time.Now()
+
+	// Build the request
+	apiReq, err := p.buildRequest(req, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Create HTTP request
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Send the request, retrying transient failures
+	var apiResp response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/api/chat"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		return p.doRequest(httpReq, &apiResp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &providers.Response{
+		Text:         apiResp.Message.Content,
+		Model:        apiResp.Model,
+		Provider:     ProviderName,
+		TokensUsed:   apiResp.PromptEvalCount + apiResp.EvalCount,
+		InputTokens:  apiResp.PromptEvalCount,
+		OutputTokens: apiResp.EvalCount,
+		ResponseTime: time.Since(startTime),
+	}
+
+	return response, nil
+}
+
+// Stream implements the providers.ProviderV2 interface
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	// Build the request
+	apiReq, err := p.buildRequest(req, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Create HTTP request
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Open the stream, retrying transient failures. Errors after the
+	// first chunk are reported on the channel and not retried.
+	var resp *http.Response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/api/chat"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		p.setHeaders(httpReq)
+
+		resp, err = providers.StreamClient(p.httpClient).Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed (is Ollama running at %s?): %w", p.baseURL, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return parseErrorResponse(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse)
+	go readStream(ctx, resp.Body, chunks)
+
+	return chunks, nil
+}
+
+// ValidateConfig implements the providers.Provider interface.
+// A local Ollama server does not require an API key.
+func (p *Provider) ValidateConfig() error {
+	if p.cfg.Model == "" {
+		return providers.ErrInvalidModel
+	}
+
+	if !strings.HasPrefix(p.baseURL, "http://") && !strings.HasPrefix(p.baseURL, "https://") {
+		return providers.ErrInvalidBaseURL
+	}
+
+	if p.cfg.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", p.cfg.MaxTokens)
+	}
+
+	return p.cfg.ValidateSampling(maxTemperature)
+}
+
+// GetInfo implements the providers.Provider interface
+func (p *Provider) GetInfo() providers.ProviderInfo {
+	return providers.ProviderInfo{
+		Name:        displayName,
+		Type:        ProviderName,
+		Model:       p.cfg.Model,
+		Description: description,
+	}
+}
+
+// GetCapabilities implements the providers.Provider interface
+func (p *Provider) GetCapabilities() providers.Capabilities {
+	return p.cfg.Capabilities.Apply(providers.Capabilities{
+		Streaming:          true,
+		FunctionCalling:    false,
+		CodeExecution:      false,
+		ImageAnalysis:      false, // depends on the model; enable with a capabilities override
+		ConversationMemory: true,
+		MaxContextSize:     8192,
+		MaxTokens:          4096,
+	})
+}
+
+// GetModels implements the providers.Provider interface
+func (p *Provider) GetModels() ([]string, error) {
+	httpReq, err := http.NewRequest("GET", fmt.Sprint(p.baseURL, "/api/tags"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// Send request and parse response
+	var tagsResp tagsResponse
+	if err := p.doRequest(httpReq, &tagsResp); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, len(tagsResp.Models))
+	for i, model := range tagsResp.Models {
+		models[i] = model.Name
+	}
+
+	return models, nil
+}
+
+// Ping implements the providers.Pinger interface by checking that the
+// configured model has been pulled
+func (p *Provider) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprint(p.baseURL, "/api/tags"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	var tagsResp tagsResponse
+	if err := p.doRequest(httpReq, &tagsResp); err != nil {
+		return err
+	}
+
+	for _, model := range tagsResp.Models {
+		// Models pulled without a tag are listed as :latest
+		if model.Name == p.cfg.Model || model.Name == p.cfg.Model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s has not been pulled (run: ollama pull %s)", providers.ErrInvalidModel, p.cfg.Model, p.cfg.Model)
+}
+
+// buildRequest creates an API request
+func (p *Provider) buildRequest(req *providers.Request, stream bool) (*request, error) {
+	// Build system prompt with context
+	systemPrompt, err := req.SystemPrompt(p.cfg.SystemPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []message{
+		{Role: "system", Content: systemPrompt},
+	}
+	for _, msg := range req.Messages {
+		m := message{Role: msg.Role, Content: msg.Content}
+		for _, image := range msg.Images {
+			m.Images = append(m.Images, image.Base64())
+		}
+		messages = append(messages, m)
+	}
+
+	return &request{
+		Model:    p.cfg.Model,
+		Messages: messages,
+		Stream:   stream,
+		Options: &options{
+			// Without it, the server truncates prompts beyond its own
+			// default context size, which is smaller than the one advertised
+			NumCtx:      p.GetCapabilities().MaxContextSize,
+			NumPredict:  req.MaxTokens(p.cfg.MaxTokens),
+			Temperature: req.Temperature(p.cfg.Temperature),
+			TopP:        req.TopP(p.cfg.TopP),
+			Stop:        req.Options.StopSequences,
+		},
+	}, nil
+}
+
+// doRequest sends an HTTP request and parses the response into the provided struct
+func (p *Provider) doRequest(httpReq *http.Request, response interface{}) error {
+	p.setHeaders(httpReq)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed (is Ollama running at %s?): %w", p.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// setHeaders adds the headers common to all API requests
+func (p *Provider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// An API key is only needed when the server sits behind an authenticating proxy
+	if p.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+
+	for key, value := range p.cfg.CustomHeaders {
+		httpReq.Header.Set(key, value)
+	}
+}
+
+// parseErrorResponse converts a non-200 API response into a typed error
+func parseErrorResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var errorResp errorResponse
+	if json.Unmarshal(body, &errorResp) == nil && errorResp.Error != "" {
+		return providers.NewAPIError(ProviderName, resp.StatusCode, resp.Header, "", errorResp.Error)
+	}
+	return providers.NewAPIError(ProviderName, resp.StatusCode, resp.Header, "", string(body))
+}
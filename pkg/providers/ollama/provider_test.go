@@ -0,0 +1,62 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// newTestProvider returns a provider sending its requests to handler
+func newTestProvider(t *testing.T, cfg providers.Config, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.Type = ProviderName
+	cfg.Model = "llama3.1:8b"
+	cfg.BaseURL = server.URL
+	cfg.MaxTokens = 1000
+	cfg.Retry = &providers.RetryPolicy{MaxRetries: new(int)}
+
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider.(*Provider)
+}
+
+func TestSendContextSize(t *testing.T) {
+	tests := []struct {
+		name string
+		caps *providers.CapabilityOverrides
+		want int
+	}{
+		{"default", nil, 8192},
+		{"configured", &providers.CapabilityOverrides{MaxContextSize: 32768}, 32768},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent request
+			p := newTestProvider(t, providers.Config{Capabilities: tt.caps}, func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&sent)
+				json.NewEncoder(w).Encode(response{Model: "llama3.1:8b", Message: message{Role: "assistant", Content: "hi"}, Done: true})
+			})
+
+			if _, err := p.Send(context.Background(), providers.NewRequest("hello", nil)); err != nil {
+				t.Fatal(err)
+			}
+			if sent.Options == nil || sent.Options.NumCtx != tt.want {
+				t.Errorf("options = %+v, want num_ctx %d", sent.Options, tt.want)
+			}
+			if caps := p.GetCapabilities(); caps.MaxContextSize != tt.want {
+				t.Errorf("advertised context size %d, want %d", caps.MaxContextSize, tt.want)
+			}
+		})
+	}
+}
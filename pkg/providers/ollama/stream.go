@@ -0,0 +1,65 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// readStream consumes the newline-delimited JSON objects from body and emits chunks on out
+func readStream(ctx context.Context, body io.ReadCloser, out chan<- providers.StreamResponse) {
+	defer close(out)
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk response
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("failed to decode stream chunk: %w", err)})
+			return
+		}
+
+		if chunk.Error != "" {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("API error: %s", chunk.Error)})
+			return
+		}
+
+		if chunk.Message.Content != "" {
+			if !providers.SendChunk(ctx, out, providers.StreamResponse{Text: chunk.Message.Content}) {
+				return
+			}
+		}
+
+		// The final object carries the stop reason and token counts
+		if chunk.Done {
+			providers.SendChunk(ctx, out, providers.StreamResponse{
+				Done: true,
+				Metadata: map[string]interface{}{
+					providers.MetadataModel:        chunk.Model,
+					providers.MetadataStopReason:   chunk.DoneReason,
+					providers.MetadataInputTokens:  chunk.PromptEvalCount,
+					providers.MetadataOutputTokens: chunk.EvalCount,
+				},
+			})
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("failed to read stream: %w", err)})
+		return
+	}
+
+	providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("%w: stream ended before the final chunk", providers.ErrServiceUnavailable)})
+}
@@ -0,0 +1,118 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// piecesHandler answers with body cut into the given pieces, flushing after
+// each so the client reads them separately
+func piecesHandler(pieces ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, piece := range pieces {
+			fmt.Fprint(w, piece)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+// collect reads a stream to the end, returning its text, final chunk and
+// error, if any
+func collect(t *testing.T, p *Provider) (string, providers.StreamResponse, error) {
+	t.Helper()
+
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("untar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		text string
+		last providers.StreamResponse
+	)
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			return text, chunk, chunk.Error
+		}
+		text += chunk.Text
+		last = chunk
+	}
+	return text, last, nil
+}
+
+const (
+	firstChunk = `{"model": "llama3.1:8b", "message": {"role": "assistant", "content": "Use "}, "done": false}` + "\n"
+	lastChunk  = `{"model": "llama3.1:8b", "message": {"role": "assistant", "content": "tar -xf."}, "done": false}` + "\n"
+	finalChunk = `{"model": "llama3.1:8b", "message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop", "prompt_eval_count": 20, "eval_count": 5}` + "\n"
+)
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+	}{
+		{"whole lines", []string{firstChunk, lastChunk, finalChunk}},
+		{"lines split across reads", []string{firstChunk[:10], firstChunk[10:] + lastChunk[:50], lastChunk[50:], finalChunk[:len(finalChunk)-1], "\n"}},
+		{"blank lines", []string{firstChunk, "\n", lastChunk, "\n\n", finalChunk}},
+		{"final chunk without a newline", []string{firstChunk, lastChunk, strings.TrimSuffix(finalChunk, "\n")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, providers.Config{}, piecesHandler(tt.pieces...))
+
+			text, last, err := collect(t, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != "Use tar -xf." {
+				t.Errorf("streamed %q", text)
+			}
+			if !last.Done || last.Metadata[providers.MetadataModel] != "llama3.1:8b" || last.Metadata[providers.MetadataStopReason] != "stop" ||
+				last.Metadata[providers.MetadataInputTokens] != 20 || last.Metadata[providers.MetadataOutputTokens] != 5 {
+				t.Errorf("final chunk = %+v, want the stream's metadata", last)
+			}
+		})
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   error
+	}{
+		{"before the final chunk", []string{firstChunk}, providers.ErrServiceUnavailable},
+		{"inside a chunk", []string{firstChunk, lastChunk[:30]}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, providers.Config{}, piecesHandler(tt.pieces...))
+
+			text, last, err := collect(t, p)
+			if err == nil || last.Done || text != "Use " {
+				t.Fatalf("stream = %q, %+v, %v; want an error after the first chunk", text, last, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamErrorChunk(t *testing.T) {
+	p := newTestProvider(t, providers.Config{}, piecesHandler(firstChunk, `{"error": "model runner has unexpectedly stopped"}`+"\n", finalChunk))
+
+	text, _, err := collect(t, p)
+	if err == nil || !strings.Contains(err.Error(), "model runner has unexpectedly stopped") || text != "Use " {
+		t.Errorf("stream = %q, %v; want the error after the first chunk", text, err)
+	}
+}
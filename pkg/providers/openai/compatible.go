@@ -0,0 +1,135 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+const (
+	// CompatibleProviderName is the provider name for OpenAI-compatible servers
+	// such as llama.cpp, vLLM and LM Studio
+	CompatibleProviderName = "openai-compatible"
+	compatibleDisplayName  = "OpenAI-compatible server"
+	compatibleDescription  = "Self-hosted server speaking the OpenAI chat completions dialect."
+
+	// compatibleTimeout is the default request timeout. Self-hosted models
+	// can be much slower than the hosted API.
+	compatibleTimeout = 5 * time.Minute
+)
+
+// compatibleCapabilities are conservative defaults for self-hosted servers,
+// which can be overridden through the provider configuration
+var compatibleCapabilities = providers.Capabilities{
+	Streaming:          true,
+	FunctionCalling:    false,
+	CodeExecution:      false,
+	ImageAnalysis:      false,
+	ConversationMemory: true,
+	MaxContextSize:     8192,
+	MaxTokens:          4096,
+}
+
+// NewCompatibleProvider creates a provider for a server exposing an
+// OpenAI-compatible /chat/completions endpoint at cfg.BaseURL
+func NewCompatibleProvider(cfg providers.Config) (providers.Provider, error) {
+	httpClient, err := cfg.HTTPClient(compatibleTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		httpClient: httpClient,
+		cfg:        cfg,
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		compatible: true,
+	}, nil
+}
+
+// validateCompatibleConfig checks the configuration of an OpenAI-compatible server.
+// Neither an API key nor a model is required, since many servers serve a single
+// model without authentication.
+func (p *Provider) validateCompatibleConfig() error {
+	if !strings.HasPrefix(p.baseURL, "http://") && !strings.HasPrefix(p.baseURL, "https://") {
+		return providers.ErrInvalidBaseURL
+	}
+
+	if p.cfg.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", p.cfg.MaxTokens)
+	}
+
+	return p.cfg.ValidateSampling(maxTemperature)
+}
+
+// fallbackModels answers GetModels for servers without a working /models endpoint
+func (p *Provider) fallbackModels(err error) ([]string, error) {
+	if p.cfg.Model != "" {
+		return []string{p.cfg.Model}, nil
+	}
+	return nil, fmt.Errorf("server does not list models, set a model in the configuration: %w", err)
+}
+
+// compatibleModels returns every model the server lists, since self-hosted
+// servers do not follow OpenAI's model naming
+func compatibleModels(resp modelsResponse) []string {
+	models := make([]string, len(resp.Data))
+	for i, model := range resp.Data {
+		models[i] = model.ID
+	}
+	return models
+}
+
+// pingCompatible checks that the server answers and, when it lists its
+// models, serves the configured one
+func (p *Provider) pingCompatible(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprint(p.baseURL, "/models"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	var modelsResp modelsResponse
+	if err := p.doRequest(httpReq, &modelsResp); err != nil {
+		// The server answered, it just has no models endpoint
+		if errors.Is(err, providers.ErrInvalidModel) {
+			return nil
+		}
+		return err
+	}
+
+	models := compatibleModels(modelsResp)
+	if p.cfg.Model == "" || len(models) == 0 {
+		return nil
+	}
+	for _, model := range models {
+		if model == p.cfg.Model {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the server does not serve %s", providers.ErrInvalidModel, p.cfg.Model)
+}
+
+// sendAsStream sends a regular request and delivers the answer as a
+// single stream chunk for servers that cannot stream
+func (p *Provider) sendAsStream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	resp, err := p.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse, 2)
+	chunks <- providers.StreamResponse{Text: resp.Text}
+	chunks <- providers.StreamResponse{
+		Done: true,
+		Metadata: map[string]interface{}{
+			providers.MetadataModel: resp.Model,
+		},
+	}
+	close(chunks)
+
+	return chunks, nil
+}
@@ -0,0 +1,490 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Provider implements the providers.Provider interface for OpenAI's chat completions API
+type Provider struct {
+	httpClient *http.Client
+	baseURL    string
+	cfg        providers.Config
+
+	// compatible relaxes the API expectations for self-hosted servers
+	compatible bool
+}
+
+// API request/response structures
+type request struct {
+	Model               string         `json:"model"`
+	Messages            []chatMessage  `json:"messages"`
+	MaxTokens           int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int            `json:"max_completion_tokens,omitempty"`
+	Stream              bool           `json:"stream,omitempty"`
+	StreamOptions       *streamOptions `json:"stream_options,omitempty"`
+	Temperature         *float32       `json:"temperature,omitempty"`
+	TopP                *float32       `json:"top_p,omitempty"`
+	Stop                []string       `json:"stop,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatMessage is a request message. Its content is a string, or a list of
+// parts when images are attached.
+type chatMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type response struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+	Usage   *usage   `json:"usage,omitempty"`
+}
+
+type choice struct {
+	Index        int     `json:"index"`
+	Message      message `json:"message"`
+	Delta        message `json:"delta"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type apiError struct {
+	Message string      `json:"message"`
+	Type    string      `json:"type"`
+	Code    interface{} `json:"code"` // a string, or a number on some compatible servers
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// modelsResponse represents the response from the models API endpoint
+type modelsResponse struct {
+	Object string  `json:"object"`
+	Data   []model `json:"data"`
+}
+
+type model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+const (
+	// ProviderName is openai gpt provider name
+	ProviderName = "openai"
+	baseURL      = "https://api.openai.com/v1"
+	displayName  = "OpenAI GPT"
+	description  = "OpenAI's GPT models through the chat completions API."
+
+	// maxTemperature is the highest sampling temperature the API accepts
+	maxTemperature = 2
+
+	// defaultTimeout limits a request unless a timeout is configured
+	defaultTimeout = 60 * time.Second
+
+	// maxImageSize is the largest image the API accepts
+	maxImageSize = 20 * 1024 * 1024
+)
+
+// NewProvider creates a new OpenAI provider instance
+func NewProvider(cfg providers.Config) (providers.Provider, error) {
+	url := baseURL
+	if cfg.BaseURL != "" {
+		url = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
+	httpClient, err := cfg.HTTPClient(defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		httpClient: httpClient,
+		cfg:        cfg,
+		baseURL:    url,
+	}, nil
+}
+
+// SendPrompt implements the providers.Provider interface
+func (p *Provider) SendPrompt(prompt string, promptContext *providers.Context) (*providers.Response, error) {
+	return p.Send(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// SendPromptStream implements streaming for the providers.Provider interface
+func (p *Provider) SendPromptStream(prompt string, promptContext *providers.Context) (<-chan providers.StreamResponse, error) {
+	return p.Stream(context.Background(), providers.NewRequest(prompt, promptContext))
+}
+
+// Send implements the providers.ProviderV2 interface
+func (p *Provider) Send(ctx context.Context, req *providers.Request) (*providers.Response, error) {
+	startTime := time.Now()
+
+	// Build the request
+	apiReq, err := p.buildRequest(req, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Create HTTP request
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Send the request, retrying transient failures
+	var apiResp response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/chat/completions"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		return p.doRequest(httpReq, &apiResp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var text string
+	if len(apiResp.Choices) > 0 {
+		text = apiResp.Choices[0].Message.Content
+	}
+
+	response := &providers.Response{
+		Text:         text,
+		Model:        apiResp.Model,
+		Provider:     p.GetInfo().Type,
+		ResponseTime: time.Since(startTime),
+	}
+	if apiResp.Usage != nil {
+		response.TokensUsed = apiResp.Usage.TotalTokens
+		response.InputTokens = apiResp.Usage.PromptTokens
+		response.OutputTokens = apiResp.Usage.CompletionTokens
+	}
+
+	return response, nil
+}
+
+// Stream implements the providers.ProviderV2 interface
+func (p *Provider) Stream(ctx context.Context, req *providers.Request) (<-chan providers.StreamResponse, error) {
+	// Servers declared without streaming support answer in a single chunk
+	if !p.GetCapabilities().Streaming {
+		return p.sendAsStream(ctx, req)
+	}
+
+	// Build the request
+	apiReq, err := p.buildRequest(req, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	// Create HTTP request
+	jsonData, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Open the stream, retrying transient failures. Errors after the
+	// first event are reported on the channel and not retried.
+	var resp *http.Response
+	err = p.cfg.RetryPolicy().Do(ctx, func() error {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprint(p.baseURL, "/chat/completions"), bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		p.setHeaders(httpReq)
+		httpReq.Header.Set("Accept", "text/event-stream")
+
+		resp, err = providers.StreamClient(p.httpClient).Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return p.parseErrorResponse(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamResponse)
+	go p.readStream(ctx, resp.Body, chunks)
+
+	return chunks, nil
+}
+
+// ValidateConfig implements the providers.Provider interface
+func (p *Provider) ValidateConfig() error {
+	if p.compatible {
+		return p.validateCompatibleConfig()
+	}
+
+	if p.cfg.APIKey == "" {
+		return providers.ErrInvalidAPIKey
+	}
+
+	if p.cfg.Model == "" {
+		return providers.ErrInvalidModel
+	}
+
+	if err := p.cfg.ValidateMaxTokens(); err != nil {
+		return err
+	}
+
+	return p.cfg.ValidateSampling(maxTemperature)
+}
+
+// GetInfo implements the providers.Provider interface
+func (p *Provider) GetInfo() providers.ProviderInfo {
+	if p.compatible {
+		return providers.ProviderInfo{
+			Name:        compatibleDisplayName,
+			Type:        CompatibleProviderName,
+			Model:       p.cfg.Model,
+			Description: compatibleDescription,
+		}
+	}
+
+	return providers.ProviderInfo{
+		Name:        displayName,
+		Type:        ProviderName,
+		Model:       p.cfg.Model,
+		Description: description,
+	}
+}
+
+// GetCapabilities implements the providers.Provider interface
+func (p *Provider) GetCapabilities() providers.Capabilities {
+	if p.compatible {
+		return p.cfg.Capabilities.Apply(compatibleCapabilities)
+	}
+
+	return p.cfg.Capabilities.Apply(providers.ModelCapabilities(p.cfg.Model, providers.Capabilities{
+		Streaming:          true,
+		FunctionCalling:    false,
+		CodeExecution:      false,
+		ImageAnalysis:      true,
+		ConversationMemory: true,
+		MaxContextSize:     128000,
+		MaxTokens:          16384,
+		MaxImageSize:       maxImageSize,
+	}))
+}
+
+// GetModels implements the providers.Provider interface
+func (p *Provider) GetModels() ([]string, error) {
+	httpReq, err := http.NewRequest("GET", fmt.Sprint(p.baseURL, "/models"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// Send request and parse response
+	var modelsResp modelsResponse
+	if err := p.doRequest(httpReq, &modelsResp); err != nil {
+		if p.compatible {
+			return p.fallbackModels(err)
+		}
+		return nil, err
+	}
+
+	if p.compatible {
+		return compatibleModels(modelsResp), nil
+	}
+
+	// The models endpoint also lists embedding, audio and image models
+	var models []string
+	for _, model := range modelsResp.Data {
+		if isChatModel(model.ID) {
+			models = append(models, model.ID)
+		}
+	}
+	sort.Strings(models)
+
+	return models, nil
+}
+
+// Ping implements the providers.Pinger interface by looking up the
+// configured model. OpenAI-compatible servers are asked for their model
+// list instead, since few can look up a single model.
+func (p *Provider) Ping(ctx context.Context) error {
+	if p.compatible {
+		return p.pingCompatible(ctx)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprint(p.baseURL, "/models/", url.PathEscape(p.cfg.Model)), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	var info model
+	return p.doRequest(httpReq, &info)
+}
+
+// isChatModel reports whether a model ID refers to a chat completions model
+func isChatModel(id string) bool {
+	for _, prefix := range []string{"gpt-", "chatgpt-", "o1", "o3", "o4"} {
+		if strings.HasPrefix(id, prefix) {
+			// Exclude audio, realtime, search and speech-to-text variants
+			for _, excluded := range []string{"audio", "realtime", "transcribe", "tts", "search", "image"} {
+				if strings.Contains(id, excluded) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// buildRequest creates an API request
+func (p *Provider) buildRequest(req *providers.Request, stream bool) (*request, error) {
+	// Build system prompt with context
+	systemPrompt, err := req.SystemPrompt(p.cfg.SystemPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []chatMessage{
+		{Role: "system", Content: systemPrompt},
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, chatMessage{Role: msg.Role, Content: messageContent(msg)})
+	}
+
+	apiReq := &request{
+		Model:       p.cfg.Model,
+		Messages:    messages,
+		Stream:      stream,
+		Temperature: req.Temperature(p.cfg.Temperature),
+		TopP:        req.TopP(p.cfg.TopP),
+		Stop:        req.Options.StopSequences,
+	}
+	maxTokens := req.MaxTokens(p.cfg.MaxTokens)
+
+	// Self-hosted servers only understand the legacy max_tokens field and
+	// may reject unknown stream options
+	if p.compatible {
+		apiReq.MaxTokens = maxTokens
+		return apiReq, nil
+	}
+
+	apiReq.MaxCompletionTokens = maxTokens
+	if stream {
+		apiReq.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	return apiReq, nil
+}
+
+// messageContent returns the content of a request message: plain text, or
+// text and image parts when images are attached
+func messageContent(msg providers.Message) interface{} {
+	if len(msg.Images) == 0 {
+		return msg.Content
+	}
+
+	parts := []contentPart{{Type: "text", Text: msg.Content}}
+	for _, image := range msg.Images {
+		parts = append(parts, contentPart{
+			Type:     "image_url",
+			ImageURL: &imageURL{URL: image.DataURL()},
+		})
+	}
+	return parts
+}
+
+// doRequest sends an HTTP request and parses the response into the provided struct
+func (p *Provider) doRequest(httpReq *http.Request, response interface{}) error {
+	p.setHeaders(httpReq)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return p.parseErrorResponse(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// setHeaders adds the headers common to all API requests
+func (p *Provider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// Self-hosted servers often run without authentication
+	if p.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+
+	for key, value := range p.cfg.CustomHeaders {
+		httpReq.Header.Set(key, value)
+	}
+}
+
+// parseErrorResponse converts a non-200 API response into a typed error
+func (p *Provider) parseErrorResponse(resp *http.Response) error {
+	name := p.GetInfo().Type
+
+	body, _ := io.ReadAll(resp.Body)
+	var errorResp errorResponse
+	if json.Unmarshal(body, &errorResp) == nil && errorResp.Error.Message != "" {
+		return providers.NewAPIError(name, resp.StatusCode, resp.Header, errorResp.Error.kind(), errorResp.Error.Message)
+	}
+	return providers.NewAPIError(name, resp.StatusCode, resp.Header, "", string(body))
+}
+
+// kind returns the most specific error classification, preferring the code
+func (e apiError) kind() string {
+	if code, ok := e.Code.(string); ok && code != "" {
+		return code
+	}
+	return e.Type
+}
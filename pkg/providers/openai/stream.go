@@ -0,0 +1,107 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// streamDone is the data payload that terminates a chat completions stream
+const streamDone = "[DONE]"
+
+// streamChunk is a single chat.completion.chunk event
+type streamChunk struct {
+	response
+	Error *apiError `json:"error,omitempty"`
+}
+
+// streamState accumulates completion metadata while the stream is consumed
+type streamState struct {
+	model        string
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+}
+
+// readStream consumes the server-sent events from body and emits chunks on out
+func (p *Provider) readStream(ctx context.Context, body io.ReadCloser, out chan<- providers.StreamResponse) {
+	defer close(out)
+	defer body.Close()
+
+	reader := providers.NewSSEReader(body)
+	state := &streamState{}
+
+	for {
+		sse, err := reader.Next()
+		if err == io.EOF {
+			// Some servers close the stream after the finish reason without [DONE]
+			if state.stopReason != "" {
+				providers.SendChunk(ctx, out, providers.StreamResponse{
+					Done:     true,
+					Metadata: state.metadata(),
+				})
+				return
+			}
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("%w: stream ended before [DONE]", providers.ErrServiceUnavailable)})
+			return
+		}
+		if err != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("failed to read stream: %w", err)})
+			return
+		}
+
+		if sse.Data == streamDone {
+			providers.SendChunk(ctx, out, providers.StreamResponse{
+				Done:     true,
+				Metadata: state.metadata(),
+			})
+			return
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(sse.Data), &chunk); err != nil {
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: fmt.Errorf("failed to decode stream event: %w", err)})
+			return
+		}
+
+		if chunk.Error != nil {
+			err := providers.NewAPIError(p.GetInfo().Type, 0, nil, chunk.Error.kind(), chunk.Error.Message)
+			providers.SendChunk(ctx, out, providers.StreamResponse{Error: err})
+			return
+		}
+
+		if chunk.Model != "" {
+			state.model = chunk.Model
+		}
+
+		// The final chunk carries usage and no choices when include_usage is set
+		if chunk.Usage != nil {
+			state.inputTokens = chunk.Usage.PromptTokens
+			state.outputTokens = chunk.Usage.CompletionTokens
+		}
+
+		for _, c := range chunk.Choices {
+			if c.FinishReason != "" {
+				state.stopReason = c.FinishReason
+			}
+			if c.Delta.Content != "" {
+				if !providers.SendChunk(ctx, out, providers.StreamResponse{Text: c.Delta.Content}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// metadata returns the accumulated completion metadata for the final chunk
+func (s *streamState) metadata() map[string]interface{} {
+	return map[string]interface{}{
+		providers.MetadataModel:        s.model,
+		providers.MetadataStopReason:   s.stopReason,
+		providers.MetadataInputTokens:  s.inputTokens,
+		providers.MetadataOutputTokens: s.outputTokens,
+	}
+}
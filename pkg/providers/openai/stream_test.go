@@ -0,0 +1,162 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// newTestProvider returns a provider sending its requests to handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewProvider(providers.Config{
+		Type:      ProviderName,
+		APIKey:    "test",
+		Model:     "gpt-4o",
+		BaseURL:   server.URL,
+		MaxTokens: 1000,
+		Retry:     &providers.RetryPolicy{MaxRetries: new(int)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider.(*Provider)
+}
+
+// piecesHandler answers with body cut into the given pieces, flushing after
+// each so the client reads them separately
+func piecesHandler(pieces ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range pieces {
+			fmt.Fprint(w, piece)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+// collect reads a stream to the end, returning its text, final chunk and
+// error, if any
+func collect(t *testing.T, p *Provider) (string, providers.StreamResponse, error) {
+	t.Helper()
+
+	chunks, err := p.Stream(context.Background(), providers.NewRequest("untar", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		text string
+		last providers.StreamResponse
+	)
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			return text, chunk, chunk.Error
+		}
+		text += chunk.Text
+		last = chunk
+	}
+	return text, last, nil
+}
+
+const (
+	firstDelta = `data: {"model": "gpt-4o-2024-08-06", "choices": [{"delta": {"content": "Use "}}]}` + "\n\n"
+	lastDelta  = `data: {"choices": [{"delta": {"content": "tar -xf."}, "finish_reason": "stop"}]}` + "\n\n"
+	usageEvent = `data: {"choices": [], "usage": {"prompt_tokens": 20, "completion_tokens": 5}}` + "\n\n"
+	done       = "data: [DONE]\n\n"
+)
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+	}{
+		{"whole events", []string{firstDelta, lastDelta, usageEvent, done}},
+		{"events split across reads", []string{firstDelta[:17], firstDelta[17:] + lastDelta[:40], lastDelta[40:] + usageEvent[:len(usageEvent)-1], usageEvent[len(usageEvent)-1:], done}},
+		{"multi-line data", []string{
+			"data: {\"model\": \"gpt-4o-2024-08-06\",\ndata:  \"choices\": [{\"delta\": {\"content\": \"Use \"}}]}\n\n",
+			lastDelta, usageEvent, done,
+		}},
+		{"keep-alive comments", []string{": keep-alive\n\n", firstDelta, ": keep-alive\n\n", lastDelta, usageEvent, done}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, piecesHandler(tt.pieces...))
+
+			text, last, err := collect(t, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != "Use tar -xf." {
+				t.Errorf("streamed %q", text)
+			}
+			if !last.Done || last.Metadata[providers.MetadataModel] != "gpt-4o-2024-08-06" || last.Metadata[providers.MetadataStopReason] != "stop" ||
+				last.Metadata[providers.MetadataInputTokens] != 20 || last.Metadata[providers.MetadataOutputTokens] != 5 {
+				t.Errorf("final chunk = %+v, want the stream's metadata", last)
+			}
+		})
+	}
+}
+
+func TestStreamWithoutDone(t *testing.T) {
+	// Some servers close the stream after the finish reason
+	p := newTestProvider(t, piecesHandler(firstDelta, lastDelta))
+
+	text, last, err := collect(t, p)
+	if err != nil || text != "Use tar -xf." || !last.Done || last.Metadata[providers.MetadataStopReason] != "stop" {
+		t.Errorf("stream = %q, %+v, %v; want it complete", text, last, err)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   error
+	}{
+		{"before the finish reason", []string{firstDelta}, providers.ErrServiceUnavailable},
+		{"inside an event", []string{firstDelta, lastDelta[:30]}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, piecesHandler(tt.pieces...))
+
+			text, last, err := collect(t, p)
+			if err == nil || last.Done || text != "Use " {
+				t.Fatalf("stream = %q, %+v, %v; want an error after the first delta", text, last, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamErrorEvent(t *testing.T) {
+	p := newTestProvider(t, piecesHandler(
+		firstDelta,
+		`data: {"error": {"type": "insufficient_quota", "message": "You exceeded your current quota"}}`+"\n\n",
+		done,
+	))
+
+	text, _, err := collect(t, p)
+	var apiErr *providers.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, providers.ErrQuotaExceeded) {
+		t.Fatalf("stream error = %v, want the quota exceeded", err)
+	}
+	if text != "Use " || apiErr.Provider != ProviderName || !strings.Contains(apiErr.Message, "current quota") {
+		t.Errorf("stream = %q, %+v; want the error after the first delta", text, apiErr)
+	}
+}
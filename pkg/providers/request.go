@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"strings"
+)
+
+// Message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a single turn of a conversation
+type Message struct {
+	Role    string  `json:"role"`
+	Content string  `json:"content"`
+	Images  []Image `json:"images,omitempty"`
+}
+
+// RequestOptions are per-request settings that override the provider configuration
+type RequestOptions struct {
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	Temperature   *float32 `json:"temperature,omitempty"`
+	TopP          *float32 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	// SystemPrompt replaces the default system prompt template. It may
+	// reference {{.SystemContext}} to include the gathered context.
+	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// MaxToolTurns limits the rounds of tool calls, see Request.Tools
+	MaxToolTurns int `json:"max_tool_turns,omitempty"`
+}
+
+// Request is a prompt sent to a ProviderV2: the conversation so far, the
+// gathered context and the options for this call
+type Request struct {
+	Messages []Message      `json:"messages"`
+	Context  *Context       `json:"context,omitempty"`
+	Options  RequestOptions `json:"options"`
+
+	// Tools the model may call while answering, on providers that support
+	// function calling. OnToolCall is notified of every call.
+	Tools      []Tool         `json:"-"`
+	OnToolCall func(ToolCall) `json:"-"`
+}
+
+// NewRequest creates a request from a prompt and context, turning the
+// context's previous prompts into conversation messages
+func NewRequest(prompt string, context *Context) *Request {
+	var messages []Message
+	if context != nil {
+		for _, entry := range context.PreviousPrompts {
+			messages = append(messages,
+				Message{Role: RoleUser, Content: entry.Prompt},
+				Message{Role: RoleAssistant, Content: entry.Response},
+			)
+		}
+	}
+
+	messages = append(messages, Message{Role: RoleUser, Content: prompt})
+
+	return &Request{
+		Messages: messages,
+		Context:  context,
+	}
+}
+
+// Prompt returns the content of the last user message
+func (r *Request) Prompt() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == RoleUser {
+			return r.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// SystemPrompt builds the system prompt for the request. A per-request
+// template override takes precedence over the configured template, which
+// may be empty to use the default one.
+func (r *Request) SystemPrompt(configured string) (string, error) {
+	return BuildSystemPromptFromTemplate(r.systemPromptTemplate(configured), r.Context)
+}
+
+// SystemPromptParts builds the system prompt for the request split in two:
+// the static instructions before the context, which are the same for every
+// request and can be cached by providers, and the rest. static is empty
+// when the template starts with the context.
+func (r *Request) SystemPromptParts(configured string) (static, dynamic string, err error) {
+	templateStr := r.systemPromptTemplate(configured)
+
+	full, err := BuildSystemPromptFromTemplate(templateStr, r.Context)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Render the template around a marker to find where the context starts
+	const marker = "\x00system-context\x00"
+	marked, err := processTemplate(templateStr, TemplateData{SystemContext: marker})
+	if err != nil {
+		return "", full, nil
+	}
+
+	prefix, _, found := strings.Cut(marked, marker)
+	if !found || !strings.HasPrefix(full, prefix) {
+		return "", full, nil
+	}
+
+	return prefix, full[len(prefix):], nil
+}
+
+// systemPromptTemplate returns the system prompt template for the request:
+// the per-request override, the configured template or the default one
+func (r *Request) systemPromptTemplate(configured string) string {
+	switch {
+	case r.Options.SystemPrompt != "":
+		return r.Options.SystemPrompt
+	case configured != "":
+		return configured
+	default:
+		return systemPromptTemplate
+	}
+}
+
+// MaxTokens returns the output token limit for the request, falling back
+// to the configured limit
+func (r *Request) MaxTokens(configured int) int {
+	if r.Options.MaxTokens > 0 {
+		return r.Options.MaxTokens
+	}
+	return configured
+}
+
+// Temperature returns the sampling temperature for the request, falling back
+// to the configured one
+func (r *Request) Temperature(configured *float32) *float32 {
+	if r.Options.Temperature != nil {
+		return r.Options.Temperature
+	}
+	return configured
+}
+
+// TopP returns the nucleus sampling threshold for the request, falling back
+// to the configured one
+func (r *Request) TopP(configured *float32) *float32 {
+	if r.Options.TopP != nil {
+		return r.Options.TopP
+	}
+	return configured
+}
+
+// ProviderV2 is a provider that accepts full requests with cancellation.
+// New providers should implement it; the legacy Provider methods remain for
+// callers that have not migrated yet.
+type ProviderV2 interface {
+	Provider
+
+	// Send sends a request and waits for the complete response
+	Send(ctx context.Context, req *Request) (*Response, error)
+
+	// Stream sends a request and returns a streaming response. The channel
+	// is closed when the response completes, fails or ctx is cancelled.
+	Stream(ctx context.Context, req *Request) (<-chan StreamResponse, error)
+}
+
+// SendChunk delivers a stream chunk unless ctx is cancelled first, and
+// reports whether the chunk was delivered
+func SendChunk(ctx context.Context, out chan<- StreamResponse, chunk StreamResponse) bool {
+	select {
+	case out <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
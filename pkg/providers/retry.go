@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; nil
+	// leaves the default
+	MaxRetries *int `json:"max_retries,omitempty"`
+
+	// InitialBackoff is the base delay before the first retry, doubled for
+	// every further retry up to MaxBackoff
+	InitialBackoff time.Duration `json:"initial_backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff"`
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	maxRetries := 2
+	return RetryPolicy{
+		MaxRetries:     &maxRetries,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+// requestHookKey is the context key of the hook called before every attempt
+type requestHookKey struct{}
+
+// WithRequestHook returns a context in which RetryPolicy.Do calls hook before
+// every attempt, so that middleware sees each request sent to the provider,
+// including retries and the turns of a tool loop, rather than each prompt.
+// Hooks already set in ctx are called first. An attempt is not made when a
+// hook fails.
+func WithRequestHook(ctx context.Context, hook func(ctx context.Context) error) context.Context {
+	if previous := requestHook(ctx); previous != nil {
+		next := hook
+		hook = func(ctx context.Context) error {
+			if err := previous(ctx); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+	return context.WithValue(ctx, requestHookKey{}, hook)
+}
+
+func requestHook(ctx context.Context) func(ctx context.Context) error {
+	hook, _ := ctx.Value(requestHookKey{}).(func(ctx context.Context) error)
+	return hook
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable,
+// or the retries are exhausted. It returns the last error.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	p = p.withDefaults()
+	hook := requestHook(ctx)
+
+	for attempt := 0; ; attempt++ {
+		if hook != nil {
+			if err := hook(ctx); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		if err == nil || attempt >= *p.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		delay, ok := p.Backoff(attempt, err)
+		if !ok {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// Backoff returns the delay before retry number attempt (starting at 0).
+// A retry-after hint from the provider takes precedence; ok is false when the
+// hint exceeds MaxBackoff, since waiting that long is not worth it.
+func (p RetryPolicy) Backoff(attempt int, err error) (delay time.Duration, ok bool) {
+	p = p.withDefaults()
+
+	if hint := RetryAfter(err); hint > 0 {
+		return hint, hint <= p.MaxBackoff
+	}
+
+	// Exponential backoff with equal jitter: half fixed, half random
+	delay = p.InitialBackoff << attempt
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	half := delay / 2
+
+	return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+// withDefaults fills in the settings left unset from the default policy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.MaxRetries == nil {
+		p.MaxRetries = defaults.MaxRetries
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+	return p
+}
@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestConfigRetryPolicy(t *testing.T) {
+	defaults := DefaultRetryPolicy()
+
+	tests := []struct {
+		name  string
+		retry *RetryPolicy
+		want  RetryPolicy
+	}{
+		{"unset", nil, defaults},
+		{"backoff only", &RetryPolicy{InitialBackoff: 2 * time.Second},
+			RetryPolicy{MaxRetries: defaults.MaxRetries, InitialBackoff: 2 * time.Second, MaxBackoff: defaults.MaxBackoff}},
+		{"disabled", &RetryPolicy{MaxRetries: intPtr(0)},
+			RetryPolicy{MaxRetries: intPtr(0), InitialBackoff: defaults.InitialBackoff, MaxBackoff: defaults.MaxBackoff}},
+		{"all set", &RetryPolicy{MaxRetries: intPtr(5), InitialBackoff: time.Second, MaxBackoff: time.Minute},
+			RetryPolicy{MaxRetries: intPtr(5), InitialBackoff: time.Second, MaxBackoff: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Config{Retry: tt.retry}.RetryPolicy()
+			if *got.MaxRetries != *tt.want.MaxRetries || got.InitialBackoff != tt.want.InitialBackoff || got.MaxBackoff != tt.want.MaxBackoff {
+				t.Errorf("RetryPolicy = {%d %s %s}, want {%d %s %s}",
+					*got.MaxRetries, got.InitialBackoff, got.MaxBackoff,
+					*tt.want.MaxRetries, tt.want.InitialBackoff, tt.want.MaxBackoff)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	// A short retry-after hint keeps the test fast
+	unavailable := &APIError{Err: ErrServiceUnavailable, RetryAfter: time.Millisecond}
+
+	tests := []struct {
+		name       string
+		maxRetries *int
+		err        error
+		attempts   int
+	}{
+		{"default", nil, unavailable, 3},
+		{"disabled", intPtr(0), unavailable, 1},
+		{"one retry", intPtr(1), unavailable, 2},
+		{"not retryable", intPtr(3), ErrInvalidAPIKey, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := RetryPolicy{MaxRetries: tt.maxRetries}.Do(context.Background(), func() error {
+				attempts++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Do = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("made %d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestWithRequestHook(t *testing.T) {
+	var calls []string
+	hook := func(name string, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	// Hooks are called in the order they were added, before every attempt
+	ctx := WithRequestHook(context.Background(), hook("outer", nil))
+	ctx = WithRequestHook(ctx, hook("inner", nil))
+
+	attempts := 0
+	unavailable := &APIError{Err: ErrServiceUnavailable, RetryAfter: time.Millisecond}
+	RetryPolicy{MaxRetries: intPtr(1)}.Do(ctx, func() error {
+		attempts++
+		return unavailable
+	})
+	if want := []string{"outer", "inner", "outer", "inner"}; attempts != 2 || !reflect.DeepEqual(calls, want) {
+		t.Errorf("hooks called %v over %d attempts, want %v over 2", calls, attempts, want)
+	}
+
+	// A failing hook stops the attempt
+	stop := errors.New("stop")
+	attempts = 0
+	err := RetryPolicy{}.Do(WithRequestHook(context.Background(), hook("failing", stop)), func() error {
+		attempts++
+		return nil
+	})
+	if !errors.Is(err, stop) || attempts != 0 {
+		t.Errorf("Do = %v after %d attempts, want the hook's error and none", err, attempts)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}
+
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		delay, ok := policy.Backoff(attempt, ErrServiceUnavailable)
+		if !ok || delay < want/2 || delay > want {
+			t.Errorf("Backoff(%d) = %s, %v, want between %s and %s", attempt, delay, ok, want/2, want)
+		}
+	}
+
+	if _, ok := policy.Backoff(0, &APIError{Err: ErrRateLimitExceeded, RetryAfter: time.Minute}); ok {
+		t.Error("a retry-after hint beyond the maximum backoff was accepted")
+	}
+}
@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// SSEEvent represents a single server-sent event
+type SSEEvent struct {
+	Event string
+	Data  string
+	ID    string
+}
+
+// SSEReader reads server-sent events from a stream
+type SSEReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewSSEReader creates a new server-sent events reader
+func NewSSEReader(r io.Reader) *SSEReader {
+	scanner := bufio.NewScanner(r)
+	// Allow large events such as long content deltas
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	return &SSEReader{scanner: scanner}
+}
+
+// Next returns the next event in the stream, or io.EOF when the stream ends
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	var event SSEEvent
+	var data []string
+	hasFields := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		// A blank line dispatches the event
+		if line == "" {
+			if hasFields {
+				event.Data = strings.Join(data, "\n")
+				return &event, nil
+			}
+			continue
+		}
+
+		// Lines starting with a colon are comments
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		hasFields = true
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Dispatch a trailing event that was not followed by a blank line
+	if hasFields {
+		event.Data = strings.Join(data, "\n")
+		return &event, nil
+	}
+
+	return nil, io.EOF
+}
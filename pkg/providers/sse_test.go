@@ -0,0 +1,96 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// readEvents serves body in the given pieces, flushing after each, and
+// returns the events read from the response
+func readEvents(t *testing.T, pieces ...string) []SSEEvent {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range pieces {
+			fmt.Fprint(w, piece)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var events []SSEEvent
+	reader := NewSSEReader(resp.Body)
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *event)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   []SSEEvent
+	}{
+		{
+			"events split across reads",
+			[]string{"da", "ta: {\"a\":", " 1}\n", "\ndata: two\n", "\n"},
+			[]SSEEvent{{Data: `{"a": 1}`}, {Data: "two"}},
+		},
+		{
+			"multi-line data",
+			[]string{"data: first\ndata: second\ndata:third\n\n"},
+			[]SSEEvent{{Data: "first\nsecond\nthird"}},
+		},
+		{
+			"event and id fields",
+			[]string{"event: message_start\nid: 7\ndata: {}\n\nevent: ping\n\n"},
+			[]SSEEvent{{Event: "message_start", ID: "7", Data: "{}"}, {Event: "ping"}},
+		},
+		{
+			"comments and blank lines",
+			[]string{": keep-alive\n\n\n: another\ndata: only\n\n"},
+			[]SSEEvent{{Data: "only"}},
+		},
+		{
+			"done marker",
+			[]string{"data: {}\n\n", "data: [DONE]\n\n"},
+			[]SSEEvent{{Data: "{}"}, {Data: "[DONE]"}},
+		},
+		{
+			"trailing event without a blank line",
+			[]string{"data: one\n\ndata: tw", "o"},
+			[]SSEEvent{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			"empty stream",
+			[]string{""},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readEvents(t, tt.pieces...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,10 @@
-package anthropic
+package providers
 
 import (
 	"bytes"
 	"fmt"
 	"strings"
 	"text/template"
-
-	"github.com/Codilas/how/pkg/providers"
 )
 
 // TemplateData represents the data available for template processing
@@ -14,18 +12,12 @@ type TemplateData struct {
 	SystemContext string
 }
 
-// systemPromptTemplate is the base template for the system prompt
+// systemPromptTemplate is the base template for the system prompt. The
+// context comes last, so that providers can cache the instructions before it.
 var systemPromptTemplate = strings.TrimSpace(`
 You are an AI assistant integrated into a shell environment, designed to provide practical and actionable advice for command line tasks, programming, and system administration. 
 Your responses should be concise, accurate, and tailored to the user's needs.
 
-System context information:
-<system_context>
-{{.SystemContext}}
-</system_context>
-
-The system context may contain information about the current directory, shell, recent commands, file context, git context, and project context. Not all of this information will always be present.
-
 Guidelines for responding:
 - Always prioritize safety and best practices in your advice.
 - Provide step-by-step instructions when appropriate.
@@ -131,11 +123,41 @@ Example:
 }
 </structured_commands>
 
+System context information:
+<system_context>
+{{.SystemContext}}
+</system_context>
+
+The system context may contain information about the current directory, shell, recent commands, file context, git context, and project context. Not all of this information will always be present.
+
 If the user's query cannot be answered based on the provided context or falls outside your capabilities, politely explain the limitation and suggest alternatives if possible.
 
 Begin your response now. Remember to tailor your answer to the specific query and context provided, and format your response according to the guidelines above.
 `)
 
+// BuildSystemPrompt creates the system prompt shared by all providers,
+// embedding the context information
+func BuildSystemPrompt(ctx *Context) (string, error) {
+	return BuildSystemPromptFromTemplate(systemPromptTemplate, ctx)
+}
+
+// BuildSystemPromptFromTemplate creates a system prompt from a custom
+// template, which may reference {{.SystemContext}}
+func BuildSystemPromptFromTemplate(templateStr string, ctx *Context) (string, error) {
+	// Create template data
+	data := TemplateData{
+		SystemContext: BuildSystemContext(ctx),
+	}
+
+	// Process the template
+	prompt, err := processTemplate(templateStr, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to process system prompt template: %w", err)
+	}
+
+	return prompt, nil
+}
+
 // processTemplate processes a template with the given data
 func processTemplate(templateStr string, data TemplateData) (string, error) {
 	tmpl, err := template.New("prompt").Parse(templateStr)
@@ -151,8 +173,8 @@ func processTemplate(templateStr string, data TemplateData) (string, error) {
 	return buf.String(), nil
 }
 
-// buildSystemContext builds the system context string from the context object
-func buildSystemContext(ctx *providers.Context) string {
+// BuildSystemContext builds the system context string from the context object
+func BuildSystemContext(ctx *Context) string {
 	if ctx == nil {
 		return ""
 	}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Token estimates for parts of a request that are not plain text
+const (
+	messageOverheadTokens = 4
+	imageTokens           = 1600
+)
+
+// exactCountThreshold is the share of the limit above which an estimate is
+// checked with the provider's exact token count, when it has one
+const exactCountThreshold = 0.8
+
+// TokenCounter is implemented by providers that can count the input tokens
+// of a request exactly
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req *Request) (int, error)
+}
+
+// EstimateTokens estimates the number of tokens in text. Tokenizers average
+// about four characters per token for English text and code, and about one
+// token per character for other scripts.
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateRequestTokens estimates the input tokens of a request: the system
+// prompt, every message, attached images and tool definitions. A system
+// prompt template configured for the provider is estimated as the default.
+func EstimateRequestTokens(req *Request) (int, error) {
+	systemPrompt, err := req.SystemPrompt("")
+	if err != nil {
+		return 0, err
+	}
+
+	total := EstimateTokens(systemPrompt)
+	for _, msg := range req.Messages {
+		total += messageOverheadTokens + EstimateTokens(msg.Content) + len(msg.Images)*imageTokens
+	}
+
+	for _, tool := range req.Tools {
+		definition, _ := json.Marshal(tool.Definition())
+		total += EstimateTokens(string(definition))
+	}
+
+	return total, nil
+}
+
+// ContextFit is the outcome of fitting a request into a token limit
+type ContextFit struct {
+	Request *Request
+
+	// Tokens is the size of the fitted request, exact when Exact is set
+	Tokens int
+	Exact  bool
+
+	// Trimmed lists the context sections removed to fit the limit
+	Trimmed []string
+}
+
+// FitContext makes req fit in limit input tokens, removing context sections
+// in order of increasing importance. The request is not modified; a trimmed
+// copy is returned when needed. counter may be nil to rely on estimates.
+// It fails with ErrContextTooLarge when the prompt alone is too large.
+func FitContext(ctx context.Context, req *Request, limit int, counter TokenCounter) (*ContextFit, error) {
+	estimate, err := EstimateRequestTokens(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &ContextFit{Request: req, Tokens: estimate}
+	if limit <= 0 || float64(estimate) < exactCountThreshold*float64(limit) {
+		return fit, nil
+	}
+
+	// Near the limit, an exact count avoids trimming what would have fit.
+	// The ratio to the estimate corrects the estimates of trimmed copies.
+	ratio := 1.0
+	if counter != nil {
+		if exact, err := counter.CountTokens(ctx, req); err == nil && exact > 0 {
+			ratio = float64(exact) / float64(estimate)
+			fit.Tokens, fit.Exact = exact, true
+		}
+	}
+
+	if fit.Tokens <= limit {
+		return fit, nil
+	}
+
+	trimmed := req.clone()
+	for fit.Tokens > limit {
+		section := trimNextSection(trimmed)
+		if section == "" {
+			return nil, fmt.Errorf("%w: the request needs about %d tokens and the limit is %d",
+				ErrContextTooLarge, fit.Tokens, limit)
+		}
+		if len(fit.Trimmed) == 0 || fit.Trimmed[len(fit.Trimmed)-1] != section {
+			fit.Trimmed = append(fit.Trimmed, section)
+		}
+
+		estimate, err := EstimateRequestTokens(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		fit.Tokens, fit.Exact = int(float64(estimate)*ratio), false
+	}
+
+	fit.Request = trimmed
+	return fit, nil
+}
+
+// trimNextSection removes the least important remaining piece of context
+// from req and returns the name of its section, or an empty string when
+// nothing is left to remove
+func trimNextSection(req *Request) string {
+	c := req.Context
+
+	if c != nil && c.Git != nil && c.Git.Status != "" {
+		c.Git.Status = ""
+		return "git status"
+	}
+
+	// Oldest commands first
+	if c != nil && len(c.RecentCommands) > 0 {
+		c.RecentCommands = c.RecentCommands[len(c.RecentCommands)/2+1:]
+		return "recent commands"
+	}
+
+	// Oldest exchange first, keeping the prompt itself
+	if len(req.Messages) > 2 {
+		req.Messages = req.Messages[2:]
+		return "conversation history"
+	}
+
+	if c != nil && c.Project != nil {
+		c.Project = nil
+		return "project information"
+	}
+
+	if c != nil && c.Git != nil {
+		c.Git = nil
+		return "git information"
+	}
+
+	return ""
+}
+
+// clone copies the parts of a request that trimming modifies
+func (r *Request) clone() *Request {
+	clone := *r
+	clone.Messages = append([]Message(nil), r.Messages...)
+
+	if r.Context != nil {
+		c := *r.Context
+		if r.Context.Git != nil {
+			git := *r.Context.Git
+			c.Git = &git
+		}
+		clone.Context = &c
+	}
+
+	return &clone
+}
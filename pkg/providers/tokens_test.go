@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"日本語", 3},
+		{"ab日本", 3},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+// fitRequest is a follow-up question with every section of context
+func fitRequest() *Request {
+	var commands []CommandHistory
+	for i := 0; i < 8; i++ {
+		commands = append(commands, CommandHistory{Command: fmt.Sprintf("make target-%d %s", i, strings.Repeat("x", 200))})
+	}
+
+	return NewRequest("why does the build fail?", &Context{
+		WorkingDirectory: "/src/app",
+		RecentCommands:   commands,
+		Git:              &GitContext{Repository: "app", Branch: "main", Status: strings.Repeat("M file.go\n", 800)},
+		Project:          &ProjectContext{Type: "go", Name: "app"},
+		PreviousPrompts: []HistoryEntry{
+			{Prompt: "what does make do", Response: strings.Repeat("It builds. ", 100)},
+			{Prompt: "and make test", Response: strings.Repeat("It tests. ", 100)},
+		},
+	})
+}
+
+func mustEstimate(t *testing.T, req *Request) int {
+	t.Helper()
+	tokens, err := EstimateRequestTokens(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func TestFitContextWithinLimit(t *testing.T) {
+	req := fitRequest()
+
+	fit, err := FitContext(context.Background(), req, 1000000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fit.Request != req || len(fit.Trimmed) > 0 {
+		t.Errorf("request within the limit was trimmed: %v", fit.Trimmed)
+	}
+}
+
+func TestFitContextTrimsLeastImportantFirst(t *testing.T) {
+	req := fitRequest()
+	full := mustEstimate(t, req)
+
+	// Dropping the git status is enough
+	fit, err := FitContext(context.Background(), req, full-1000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fit.Trimmed, []string{"git status"}) {
+		t.Errorf("trimmed %v, want the git status only", fit.Trimmed)
+	}
+	if fit.Tokens > full-1000 || fit.Exact {
+		t.Errorf("fitted to %d tokens (exact %v), want an estimate within %d", fit.Tokens, fit.Exact, full-1000)
+	}
+	if req.Context.Git.Status == "" {
+		t.Error("the original request was modified")
+	}
+
+	// With room for little more than the question, everything goes
+	minimal := mustEstimate(t, NewRequest("why does the build fail?", &Context{WorkingDirectory: "/src/app"}))
+	fit, err = FitContext(context.Background(), req, minimal+10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"git status", "recent commands", "conversation history", "project information", "git information"}
+	if !reflect.DeepEqual(fit.Trimmed, want) {
+		t.Errorf("trimmed %v, want %v", fit.Trimmed, want)
+	}
+	if fit.Request.Prompt() != "why does the build fail?" || len(fit.Request.Messages) != 1 {
+		t.Errorf("the question was not kept alone: %+v", fit.Request.Messages)
+	}
+}
+
+func TestFitContextTooLarge(t *testing.T) {
+	req := NewRequest(strings.Repeat("word ", 1000), nil)
+
+	_, err := FitContext(context.Background(), req, 100, nil)
+	if !errors.Is(err, ErrContextTooLarge) {
+		t.Fatalf("FitContext = %v, want ErrContextTooLarge", err)
+	}
+}
+
+// fixedCounter counts every request as the same number of tokens
+type fixedCounter int
+
+func (c fixedCounter) CountTokens(ctx context.Context, req *Request) (int, error) {
+	return int(c), nil
+}
+
+func TestFitContextUsesExactCount(t *testing.T) {
+	req := fitRequest()
+	full := mustEstimate(t, req)
+
+	// The estimate is over the limit, but the exact count is not
+	fit, err := FitContext(context.Background(), req, full-100, fixedCounter(full-200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fit.Trimmed) > 0 || !fit.Exact || fit.Tokens != full-200 {
+		t.Errorf("fit = %d tokens (exact %v), trimmed %v; want the exact count and no trimming", fit.Tokens, fit.Exact, fit.Trimmed)
+	}
+
+	// Below the threshold, the counter is not consulted
+	fit, err = FitContext(context.Background(), req, full*2, fixedCounter(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fit.Exact {
+		t.Error("the counter was consulted well below the limit")
+	}
+}
@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultMaxToolTurns is the number of rounds of tool calls allowed per
+// request when the request does not set a limit
+const DefaultMaxToolTurns = 5
+
+// ErrToolTurnLimit is returned when the model keeps calling tools after the
+// turn limit was reached
+var ErrToolTurnLimit = fmt.Errorf("tool call limit reached")
+
+// Tool is a function the model may call to gather information
+type Tool interface {
+	// Definition describes the tool to the model
+	Definition() ToolDefinition
+
+	// Run executes the tool with the JSON input chosen by the model
+	Run(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// ToolDefinition describes a tool and its JSON schema input
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ToolCall is a tool invocation requested by the model and its outcome
+type ToolCall struct {
+	ID     string
+	Name   string
+	Input  json.RawMessage
+	Output string
+	Err    error
+}
+
+// MaxToolTurns returns the number of rounds of tool calls allowed
+func (r *Request) MaxToolTurns() int {
+	if r.Options.MaxToolTurns > 0 {
+		return r.Options.MaxToolTurns
+	}
+	return DefaultMaxToolTurns
+}
+
+// CallTool runs a tool call requested by the model and reports it through
+// OnToolCall. Failures are returned as output for the model rather than as
+// errors, so that it can recover; isError tells them apart.
+func (r *Request) CallTool(ctx context.Context, id, name string, input json.RawMessage) (output string, isError bool) {
+	call := ToolCall{ID: id, Name: name, Input: input}
+
+	var tool Tool
+	for _, t := range r.Tools {
+		if t.Definition().Name == name {
+			tool = t
+			break
+		}
+	}
+
+	if tool == nil {
+		call.Err = fmt.Errorf("unknown tool %q", name)
+	} else {
+		call.Output, call.Err = tool.Run(ctx, input)
+	}
+
+	if r.OnToolCall != nil {
+		r.OnToolCall(call)
+	}
+
+	if call.Err != nil {
+		return call.Err.Error(), true
+	}
+	return call.Output, false
+}
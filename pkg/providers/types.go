@@ -1,6 +1,9 @@
 package providers
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Context contains contextual information to include with prompts
 type Context struct {
@@ -81,8 +84,20 @@ type Response struct {
 	Model          string        `json:"model"`
 	Provider       string        `json:"provider"`
 	TokensUsed     int           `json:"tokens_used,omitempty"`
+	InputTokens    int           `json:"input_tokens,omitempty"`
+	OutputTokens   int           `json:"output_tokens,omitempty"`
 	ResponseTime   time.Duration `json:"response_time"`
 	ConversationID string        `json:"conversation_id,omitempty"`
+	Cached         bool          `json:"cached,omitempty"`
+
+	// Input tokens read from and written to the provider's prompt cache,
+	// in addition to InputTokens
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+
+	// Thinking is the model's reasoning before the answer in Text, when
+	// extended thinking is enabled
+	Thinking string `json:"thinking,omitempty"`
 
 	// Additional metadata
 	Confidence float32  `json:"confidence,omitempty"`
@@ -93,11 +108,31 @@ type Response struct {
 // StreamResponse represents a streaming response chunk
 type StreamResponse struct {
 	Text     string                 `json:"text"`
+	Thinking string                 `json:"thinking,omitempty"` // reasoning, streamed before the text
 	Done     bool                   `json:"done"`
 	Error    error                  `json:"error,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// Common StreamResponse metadata keys
+const (
+	MetadataModel        = "model"
+	MetadataStopReason   = "stop_reason"
+	MetadataInputTokens  = "input_tokens"
+	MetadataOutputTokens = "output_tokens"
+
+	// Input tokens read from and written to the provider's prompt cache
+	MetadataCacheReadTokens  = "cache_read_tokens"
+	MetadataCacheWriteTokens = "cache_write_tokens"
+
+	// MetadataProvider names the configured provider that answered, which
+	// differs from the requested one after a fallback
+	MetadataProvider = "provider"
+
+	// MetadataCached is true when the response was replayed from the cache
+	MetadataCached = "cached"
+)
+
 // SuggestedCommand represents a command the AI suggests
 type SuggestedCommand struct {
 	Command     string `json:"command"`
@@ -139,6 +174,41 @@ type Capabilities struct {
 	ConversationMemory bool `json:"conversation_memory"`
 	MaxContextSize     int  `json:"max_context_size"`
 	MaxTokens          int  `json:"max_tokens"`
+
+	// MaxImageSize is the largest image accepted, in bytes; zero when
+	// unknown
+	MaxImageSize int `json:"max_image_size,omitempty"`
+}
+
+// CapabilityOverrides declares capabilities that cannot be detected from a
+// provider, such as the context size of a self-hosted model
+type CapabilityOverrides struct {
+	Streaming      *bool `json:"streaming,omitempty"`
+	ImageAnalysis  *bool `json:"image_analysis,omitempty"`
+	MaxContextSize int   `json:"max_context_size,omitempty"`
+	MaxTokens      int   `json:"max_tokens,omitempty"`
+}
+
+// Apply returns caps with the declared overrides applied
+func (o *CapabilityOverrides) Apply(caps Capabilities) Capabilities {
+	if o == nil {
+		return caps
+	}
+
+	if o.Streaming != nil {
+		caps.Streaming = *o.Streaming
+	}
+	if o.ImageAnalysis != nil {
+		caps.ImageAnalysis = *o.ImageAnalysis
+	}
+	if o.MaxContextSize > 0 {
+		caps.MaxContextSize = o.MaxContextSize
+	}
+	if o.MaxTokens > 0 {
+		caps.MaxTokens = o.MaxTokens
+	}
+
+	return caps
 }
 
 // Config represents configuration for any provider
@@ -148,4 +218,69 @@ type Config struct {
 	Model     string `json:"model"`
 	BaseURL   string `json:"base_url"`
 	MaxTokens int    `json:"max_tokens"`
+
+	// Sampling settings and system prompt template used unless a request
+	// overrides them; nil leaves the provider's default
+	Temperature  *float32 `json:"temperature,omitempty"`
+	TopP         *float32 `json:"top_p,omitempty"`
+	SystemPrompt string   `json:"system_prompt,omitempty"`
+
+	// ThinkingBudget enables extended thinking with up to this many tokens
+	// of reasoning, on providers that support it
+	ThinkingBudget int `json:"thinking_budget,omitempty"`
+
+	// DisablePromptCaching turns off caching of the system prompt and
+	// history by providers that support it
+	DisablePromptCaching bool `json:"disable_prompt_caching,omitempty"`
+
+	// CustomHeaders are added to every HTTP request, e.g. for a gateway
+	CustomHeaders map[string]string    `json:"custom_headers,omitempty"`
+	Capabilities  *CapabilityOverrides `json:"capabilities,omitempty"`
+
+	// Fixtures is the fixture file answered from by the mock provider
+	Fixtures string `json:"fixtures,omitempty"`
+
+	// Command and Args start the plugin the exec provider forwards to
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	// Timeout limits how long to wait for an answer; zero leaves the
+	// provider's default
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Network configures the HTTP client of HTTP-based providers
+	Network *NetworkConfig `json:"network,omitempty"`
+
+	// Retry overrides the default retry policy
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// ValidateSampling checks the configured temperature, top-p and system
+// prompt template. maxTemperature is the highest temperature the provider
+// accepts.
+func (c Config) ValidateSampling(maxTemperature float32) error {
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > maxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %g, got %g", maxTemperature, *c.Temperature)
+	}
+
+	if c.TopP != nil && (*c.TopP <= 0 || *c.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1, got %g", *c.TopP)
+	}
+
+	if c.SystemPrompt != "" {
+		if _, err := BuildSystemPromptFromTemplate(c.SystemPrompt, nil); err != nil {
+			return fmt.Errorf("invalid system prompt: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RetryPolicy returns the configured retry policy, with the settings left
+// out taken from the default one
+func (c Config) RetryPolicy() RetryPolicy {
+	if c.Retry != nil {
+		return c.Retry.withDefaults()
+	}
+	return DefaultRetryPolicy()
 }
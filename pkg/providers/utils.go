@@ -91,9 +91,9 @@ func ExtractCodeBlocks(text string) []CodeBlock {
 		}
 	}
 
-	fmt.Println("\n---------------------------\n")
+	fmt.Print("\n---------------------------\n\n")
 	fmt.Printf("Extracted code blocks: %+v", blocks)
-	fmt.Println("\n---------------------------\n")
+	fmt.Print("\n---------------------------\n\n")
 
 	return blocks
 }
@@ -120,9 +120,9 @@ func ExtractCommands(text string) []SuggestedCommand {
 		}
 	}
 
-	fmt.Println("\n---------------------------\n")
+	fmt.Print("\n---------------------------\n\n")
 	fmt.Printf("Extracted commands: %+v", commands)
-	fmt.Println("\n---------------------------\n")
+	fmt.Print("\n---------------------------\n\n")
 
 	return commands
 }
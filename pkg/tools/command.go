@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// commandTimeout bounds how long a single command may run
+const commandTimeout = 10 * time.Second
+
+// DefaultAllowedCommands are the read-only commands the model may run. An
+// entry of several words only allows that subcommand.
+var DefaultAllowedCommands = []string{
+	"ls", "cat", "head", "tail", "wc", "file", "stat", "du", "df", "pwd", "uname", "which", "grep",
+	"git log", "git status", "git diff", "git show", "git branch --list", "git remote -v",
+	"git ls-files", "git blame", "git rev-parse", "git tag --list",
+	"go version", "go env",
+}
+
+// blockedArgs are options that make otherwise read-only commands write
+// files or run other programs. The go build flags are blocked in case go
+// subcommands that accept them are allowed.
+var blockedArgs = []string{
+	"--output", "--exec", "--upload-pack", "-exec", "-execdir", "-ok", "-delete",
+	"-toolexec", "--toolexec", "-mod", "--mod", "-modfile", "--modfile", "-overlay", "--overlay",
+}
+
+// writeArgs are options and subcommands that switch an allowed subcommand
+// from reading to writing, such as deleting branches, changing remotes or
+// changing go env settings
+var writeArgs = map[string][]string{
+	"git branch": {"-d", "-D", "--delete", "-m", "-M", "--move", "-c", "-C", "--copy",
+		"-f", "--force", "-u", "--set-upstream-to", "--unset-upstream", "--edit-description"},
+	"git tag":    {"-d", "--delete", "-f", "--force", "-a", "--annotate", "-s", "--sign", "-u", "--local-user", "-m", "-F"},
+	"git remote": {"add", "remove", "rm", "rename", "set-url", "set-head", "set-branches", "prune", "update"},
+	"go env":     {"-w", "-u"},
+}
+
+// RunCommand runs an allow-listed command in Root, without a shell
+type RunCommand struct {
+	Root    string
+	Allowed []string
+}
+
+// Definition implements the providers.Tool interface
+func (t *RunCommand) Definition() providers.ToolDefinition {
+	return providers.ToolDefinition{
+		Name: "run_command",
+		Description: fmt.Sprintf("Run a read-only command in the user's working directory and return its output. "+
+			"Commands are not run by a shell, so pipes, redirects and variables are not available. "+
+			"Allowed commands: %s.", strings.Join(t.Allowed, ", ")),
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"command": map[string]interface{}{
+					"type":        "string",
+					"description": "The command line, e.g. \"git log --oneline -5\"",
+				},
+			},
+			"required": []string{"command"},
+		},
+	}
+}
+
+// Run implements the providers.Tool interface
+func (t *RunCommand) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := decodeInput(input, &args); err != nil {
+		return "", err
+	}
+
+	argv, err := splitCommand(args.Command)
+	if err != nil {
+		return "", err
+	}
+	if err := t.check(argv); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = t.Root
+	cmd.Env = append(os.Environ(), "GIT_PAGER=cat", "PAGER=cat", "GIT_TERMINAL_PROMPT=0")
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("command timed out after %s", commandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("command failed (%v):\n%s", err, truncate(string(output)))
+	}
+
+	if len(output) == 0 {
+		return "(no output)", nil
+	}
+	return truncate(string(output)), nil
+}
+
+// check rejects commands that are not allowed, options that write or
+// execute, and paths outside of Root
+func (t *RunCommand) check(argv []string) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+
+	allowed := false
+	for _, entry := range t.Allowed {
+		words := strings.Fields(entry)
+		if len(words) > 0 && len(argv) >= len(words) && strings.Join(argv[:len(words)], " ") == entry {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("command %q is not allowed; allowed commands: %s", argv[0], strings.Join(t.Allowed, ", "))
+	}
+
+	blocked := blockedArgs
+	if len(argv) > 1 {
+		blocked = append(append([]string{}, blockedArgs...), writeArgs[argv[0]+" "+argv[1]]...)
+	}
+
+	for _, arg := range argv[1:] {
+		for _, blocked := range blocked {
+			if arg == blocked || strings.HasPrefix(arg, blocked+"=") {
+				return fmt.Errorf("option %s is not allowed", arg)
+			}
+		}
+
+		if value := pathValue(arg); isPath(t.Root, value) {
+			if _, err := resolvePath(t.Root, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pathValue returns the part of an argument that may name a file: the
+// argument itself, the value of an --option=value, or the value glued to a
+// short option such as -f/etc/passwd
+func pathValue(arg string) string {
+	switch {
+	case !strings.HasPrefix(arg, "-"):
+		return arg
+	case strings.Contains(arg, "="):
+		_, value, _ := strings.Cut(arg, "=")
+		return value
+	case !strings.HasPrefix(arg, "--") && len(arg) > 2:
+		return arg[2:]
+	}
+	return ""
+}
+
+// isPath reports whether an argument value may name a file: it looks like
+// a path, or a file of that name exists in root, which may be a link
+// leading out of it
+func isPath(root, value string) bool {
+	if value == "" {
+		return false
+	}
+	if strings.ContainsAny(value, "/"+string(os.PathSeparator)) || value == ".." {
+		return true
+	}
+	_, err := os.Lstat(filepath.Join(root, value))
+	return err == nil
+}
+
+// splitCommand splits a command line into words, honoring single and
+// double quotes
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCommandCheck(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tool := &RunCommand{Root: root, Allowed: append(DefaultAllowedCommands, "go list")}
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"ls -la", true},
+		{"ls src/", true},
+		{"git log --oneline -5", true},
+		{"git log origin/main", true},
+		{"git log --format=%an/%s", true},
+		{"git branch --list", true},
+		{"go list ./...", true},
+
+		{"", false},
+		{"rm -rf src", false},
+		{"git push", false},
+		{"git branch --list -D main", false},
+		{"go env -w GOFLAGS=-x", false},
+		{"git remote -v", true},
+		{"git remote -v show origin", true},
+		{"git remote -v add evil https://example.com/evil.git", false},
+		{"git remote -v set-url origin https://example.com/evil.git", false},
+		{"git remote -v remove origin", false},
+		{"git remote -v rm origin", false},
+		{"git remote -v rename origin upstream", false},
+		{"git remote -v set-head origin main", false},
+		{"git remote -v set-branches origin main", false},
+		{"git remote -v prune origin", false},
+		{"git remote -v update", false},
+		{"cat /etc/passwd", false},
+		{"cat ../secret", false},
+		{"cat src/../../secret", false},
+		{"ls ..", false},
+
+		// Paths glued to options
+		{"grep -f/etc/shadow x", false},
+		{"file -f/etc/passwd", false},
+		{"git blame --contents=/etc/passwd README.md", false},
+		{"grep --file=../secret x", false},
+
+		// Options that write files or run programs
+		{"git log --output=log.txt", false},
+		{`go list -export -toolexec="sh -c 'touch pwned'" ./...`, false},
+		{"go list -toolexec=sh ./...", false},
+		{"go list --toolexec sh ./...", false},
+		{"go list -modfile=other.mod ./...", false},
+		{"go list -overlay overlay.json ./...", false},
+		{"go list -mod=mod ./...", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			argv, err := splitCommand(tt.command)
+			if err != nil {
+				t.Fatalf("splitCommand: %v", err)
+			}
+
+			err = tool.check(argv)
+			if tt.allowed && err != nil {
+				t.Errorf("check(%q) = %v, want allowed", tt.command, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("check(%q) allowed, want an error", tt.command)
+			}
+		})
+	}
+}
+
+func TestRunCommandCheckSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"escape": outside,
+		"leak":   filepath.Join(outside, "secret"),
+		"inner":  filepath.Join(root, "src"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	tool := &RunCommand{Root: root, Allowed: DefaultAllowedCommands}
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"ls inner", true},
+		{"grep -r x src inner", true},
+		// Arguments naming a link out of the tree are refused, even as patterns
+		{"grep leak src", false},
+
+		{"cat leak", false},
+		{"head -n 1 leak", false},
+		{"grep x leak", false},
+		{"ls escape", false},
+		{"grep --file=leak x", false},
+		{"grep -fleak x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			argv, err := splitCommand(tt.command)
+			if err != nil {
+				t.Fatalf("splitCommand: %v", err)
+			}
+
+			err = tool.check(argv)
+			if tt.allowed && err != nil {
+				t.Errorf("check(%q) = %v, want allowed", tt.command, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("check(%q) allowed, want an error", tt.command)
+			}
+		})
+	}
+}
+
+func TestDefaultAllowedCommandsExcludeGoList(t *testing.T) {
+	for _, command := range DefaultAllowedCommands {
+		if command == "go list" {
+			t.Fatal("go list runs build tools and must not be allowed by default")
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	argv, err := splitCommand(`grep -n "two words" 'single quoted' plain`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"grep", "-n", "two words", "single quoted", "plain"}
+	if len(argv) != len(want) {
+		t.Fatalf("splitCommand = %q, want %q", argv, want)
+	}
+	for i := range want {
+		if argv[i] != want[i] {
+			t.Errorf("word %d = %q, want %q", i, argv[i], want[i])
+		}
+	}
+
+	if _, err := splitCommand(`echo "unterminated`); err == nil {
+		t.Error("unterminated quote accepted")
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		inside bool
+	}{
+		{"", true},
+		{".", true},
+		{"sub", true},
+		{"sub/../sub", true},
+		{"missing/file", true},
+		{filepath.Join(root, "sub"), true},
+		{"..", false},
+		{"../x", false},
+		{"sub/../../x", false},
+		{outside, false},
+		{"escape", false},
+		{"escape/file", false},
+	}
+
+	for _, tt := range tests {
+		_, err := resolvePath(root, tt.path)
+		if tt.inside && err != nil {
+			t.Errorf("resolvePath(%q) = %v, want inside", tt.path, err)
+		}
+		if !tt.inside && err == nil {
+			t.Errorf("resolvePath(%q) accepted a path outside of root", tt.path)
+		}
+	}
+}
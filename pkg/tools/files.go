@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// ListDirectory lists the entries of a directory below Root
+type ListDirectory struct {
+	Root string
+}
+
+// Definition implements the providers.Tool interface
+func (t *ListDirectory) Definition() providers.ToolDefinition {
+	return providers.ToolDefinition{
+		Name:        "list_directory",
+		Description: "List the files and directories in a directory of the user's working directory, with their sizes.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory relative to the working directory; defaults to the working directory itself",
+				},
+			},
+		},
+	}
+}
+
+// Run implements the providers.Tool interface
+func (t *ListDirectory) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := decodeInput(input, &args); err != nil {
+		return "", err
+	}
+
+	dir, err := resolvePath(t.Root, args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, entry := range entries {
+		if i == maxListEntries {
+			fmt.Fprintf(&b, "[%d more entries]\n", len(entries)-maxListEntries)
+			break
+		}
+
+		switch info, err := entry.Info(); {
+		case err != nil:
+			fmt.Fprintf(&b, "%s\n", entry.Name())
+		case entry.IsDir():
+			fmt.Fprintf(&b, "%s/\n", entry.Name())
+		default:
+			fmt.Fprintf(&b, "%s (%d bytes)\n", entry.Name(), info.Size())
+		}
+	}
+
+	if b.Len() == 0 {
+		return "(empty directory)", nil
+	}
+	return b.String(), nil
+}
+
+// ReadFile reads a text file below Root
+type ReadFile struct {
+	Root string
+}
+
+// Definition implements the providers.Tool interface
+func (t *ReadFile) Definition() providers.ToolDefinition {
+	return providers.ToolDefinition{
+		Name:        "read_file",
+		Description: fmt.Sprintf("Read a text file in the user's working directory. Files are cut off after %d KB.", maxOutputBytes/1024),
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "File relative to the working directory",
+				},
+			},
+			"required": []string{"path"},
+		},
+	}
+}
+
+// Run implements the providers.Tool interface
+func (t *ReadFile) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := decodeInput(input, &args); err != nil {
+		return "", err
+	}
+	if args.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	path, err := resolvePath(t.Root, args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxOutputBytes+1))
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%s is a binary file", args.Path)
+	}
+
+	return truncate(string(data)), nil
+}
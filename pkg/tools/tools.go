@@ -0,0 +1,144 @@
+// Package tools provides read-only tools that let the model inspect the
+// working directory while answering.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Codilas/how/pkg/providers"
+)
+
+// Output limits, so that a single tool call cannot flood the context
+const (
+	maxOutputBytes = 32 * 1024
+	maxListEntries = 200
+)
+
+// Registry holds the tools offered to the model
+type Registry struct {
+	tools []providers.Tool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default returns a registry with the read-only tools confined to root:
+// listing directories, reading files and running the allowed commands.
+// A nil allowed list uses DefaultAllowedCommands.
+func Default(root string, allowed []string) *Registry {
+	if allowed == nil {
+		allowed = DefaultAllowedCommands
+	}
+
+	registry := NewRegistry()
+	registry.Register(&ListDirectory{Root: root})
+	registry.Register(&ReadFile{Root: root})
+	registry.Register(&RunCommand{Root: root, Allowed: allowed})
+	return registry
+}
+
+// Register adds a tool to the registry, replacing a tool of the same name
+func (r *Registry) Register(tool providers.Tool) {
+	name := tool.Definition().Name
+	for i, existing := range r.tools {
+		if existing.Definition().Name == name {
+			r.tools[i] = tool
+			return
+		}
+	}
+	r.tools = append(r.tools, tool)
+}
+
+// Tools returns the registered tools
+func (r *Registry) Tools() []providers.Tool {
+	return r.tools
+}
+
+// Describe returns a short, human readable summary of a tool call
+func Describe(call providers.ToolCall) string {
+	var input map[string]interface{}
+	json.Unmarshal(call.Input, &input)
+
+	switch call.Name {
+	case "run_command":
+		return fmt.Sprintf("$ %v", input["command"])
+	case "list_directory", "read_file":
+		path, _ := input["path"].(string)
+		if path == "" {
+			path = "."
+		}
+		return fmt.Sprintf("%s %s", call.Name, path)
+	default:
+		return fmt.Sprintf("%s %s", call.Name, string(call.Input))
+	}
+}
+
+// resolvePath resolves a path given by the model against root and rejects
+// paths outside of it
+func resolvePath(root, path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+
+	resolved := path
+	if !filepath.IsAbs(path) {
+		resolved = filepath.Join(root, path)
+	}
+	resolved = filepath.Clean(resolved)
+
+	// Resolve symlinks, so that a link cannot lead outside of root
+	resolved = evalSymlinks(resolved)
+	if evaluatedRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = evaluatedRoot
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of the working directory", path)
+	}
+
+	return resolved, nil
+}
+
+// evalSymlinks resolves the symlinks in path. For paths that do not exist,
+// the longest existing parent is resolved, so that a missing file under a
+// link is placed where the link leads.
+func evalSymlinks(path string) string {
+	var missing []string
+	for {
+		if evaluated, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{evaluated}, missing...)...)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, missing...)...)
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// truncate cuts output down to the output limit
+func truncate(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	return output[:maxOutputBytes] + "\n[output truncated]"
+}
+
+// decodeInput decodes the JSON input of a tool call
+func decodeInput(input json.RawMessage, v interface{}) error {
+	if len(input) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(input, v); err != nil {
+		return fmt.Errorf("invalid input: %w", err)
+	}
+	return nil
+}